@@ -0,0 +1,90 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/renehernandez/appfile/internal/apps"
+	"github.com/renehernandez/appfile/internal/errors"
+	"github.com/spf13/cobra"
+)
+
+type applyCmd struct {
+	*rootCmd
+
+	output string
+}
+
+var (
+	applyLong = `Apply the changes recorded in a plan file by the plan command
+
+The appfile is read to configure the access tokens and the hooks of the apps, while the
+app specs are taken from the plan. The environment is the one the plan was made for.
+
+apply refuses to run if the plan was made by a different version of appfile, or if any of
+the apps changed in DigitalOcean since the plan was made.
+`
+	applyExample = `  # Apply the changes recorded in plan.json
+appfile apply plan.json
+
+  # Apply a plan of an appfile in custom location
+  appfile apply plan.json --file /path/to/appfile.yaml`
+)
+
+func newApplyCmd(rootCmd *rootCmd) *cobra.Command {
+	apply := applyCmd{
+		rootCmd: rootCmd,
+	}
+
+	cmd := &cobra.Command{
+		Use:     "apply PLAN",
+		Short:   "Apply the changes recorded in a plan file",
+		Long:    applyLong,
+		Example: applyExample,
+		Args:    cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return apply.run(args[0], cmd.Flags().Changed("environment"))
+		},
+	}
+
+	cmd.Flags().StringVarP(&apply.output, "output", "o", outputTable, fmt.Sprintf("format of the summary: %s or %s", outputTable, outputJSON))
+
+	return cmd
+}
+
+func (apply *applyCmd) run(file string, environmentSet bool) error {
+	if err := validateOutput(apply.output); err != nil {
+		return err
+	}
+
+	plan, err := apps.ReadPlan(file)
+	if err != nil {
+		return err
+	}
+
+	if environmentSet && apply.environment != plan.Environment {
+		return errors.New(errors.KindConfig, "Plan was made for environment %s and cannot be applied to environment %s", plan.Environment, apply.environment)
+	}
+	apply.environment = plan.Environment
+
+	appfile, err := apply.appfileFromSpec()
+	if err != nil {
+		return err
+	}
+
+	if err := apply.loadAppliedState(appfile); err != nil {
+		return err
+	}
+
+	ctx, cancel := apply.newContext()
+	defer cancel()
+
+	summary, err := appfile.Apply(ctx, plan)
+	err = apply.saveAppliedState(appfile, err)
+	if err == nil || len(summary.Results) > 0 {
+		if printErr := printSummaryAs(summary, apply.output); printErr != nil {
+			return printErr
+		}
+	}
+
+	return err
+}
@@ -0,0 +1,73 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/mattn/go-isatty"
+	"github.com/pkg/errors"
+	"github.com/renehernandez/appfile/internal/apps"
+	"github.com/sergi/go-diff/diffmatchpatch"
+)
+
+// approver shows the diff of every app to sync and asks whether to apply it,
+// skip it or abort the sync
+type approver struct {
+	in  *bufio.Reader
+	out io.Writer
+	// yes approves every app without asking
+	yes bool
+}
+
+func newApprover(in io.Reader, out io.Writer, yes bool) *approver {
+	return &approver{
+		in:  bufio.NewReader(in),
+		out: out,
+		yes: yes,
+	}
+}
+
+// stdinIsTerminal reports whether the standard input can answer the prompts
+func stdinIsTerminal() bool {
+	return isatty.IsTerminal(os.Stdin.Fd()) || isatty.IsCygwinTerminal(os.Stdin.Fd())
+}
+
+func (approver *approver) approve(diff *apps.AppDiff) (string, error) {
+	appSpecDiffs, err := diff.CalculateDiff()
+	if err != nil {
+		return "", errors.Wrapf(err, "Failed to calculate diff for app %s", diff.Name)
+	}
+
+	fmt.Fprintf(approver.out, "Diff for app %s\n", diff.Name)
+	fmt.Fprintln(approver.out, diffmatchpatch.New().DiffPrettyText(appSpecDiffs))
+
+	if approver.yes {
+		fmt.Fprintf(approver.out, "Applying changes to app %s\n\n", diff.Name)
+		return apps.ApprovalApply, nil
+	}
+
+	for {
+		fmt.Fprintf(approver.out, "Apply changes to app %s? [a]pply, [s]kip, abort [q]: ", diff.Name)
+
+		answer, err := approver.in.ReadString('\n')
+		if err != nil && answer == "" {
+			return "", errors.Wrapf(err, "Failed to read the answer for app %s", diff.Name)
+		}
+
+		switch strings.ToLower(strings.TrimSpace(answer)) {
+		case "a", "apply", "y", "yes":
+			return apps.ApprovalApply, nil
+		case "s", "skip", "n", "no":
+			return apps.ApprovalSkip, nil
+		case "q", "abort":
+			return apps.ApprovalAbort, nil
+		}
+
+		if err != nil {
+			return "", errors.Wrapf(err, "Failed to read the answer for app %s", diff.Name)
+		}
+	}
+}
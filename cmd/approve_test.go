@@ -0,0 +1,54 @@
+package cmd
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/renehernandez/appfile/internal/apps"
+	"github.com/stretchr/testify/suite"
+)
+
+type ApproveTestSuite struct {
+	suite.Suite
+}
+
+func (suite *ApproveTestSuite) TestAnswers() {
+	answers := map[string]string{
+		"a\n":      apps.ApprovalApply,
+		"yes\n":    apps.ApprovalApply,
+		"s\n":      apps.ApprovalSkip,
+		"q\n":      apps.ApprovalAbort,
+		"maybe\nn": apps.ApprovalSkip,
+	}
+
+	for input, expected := range answers {
+		var out bytes.Buffer
+		approval, err := newApprover(strings.NewReader(input), &out, false).approve(&apps.AppDiff{Name: "web"})
+
+		suite.Require().NoError(err)
+		suite.Equal(expected, approval, input)
+		suite.Contains(out.String(), "Apply changes to app web?")
+	}
+}
+
+func (suite *ApproveTestSuite) TestClosedInputFails() {
+	var out bytes.Buffer
+	_, err := newApprover(strings.NewReader("maybe\n"), &out, false).approve(&apps.AppDiff{Name: "web"})
+
+	suite.Error(err)
+	suite.Contains(err.Error(), "Failed to read the answer for app web")
+}
+
+func (suite *ApproveTestSuite) TestYesApprovesWithoutAsking() {
+	var out bytes.Buffer
+	approval, err := newApprover(strings.NewReader(""), &out, true).approve(&apps.AppDiff{Name: "web"})
+
+	suite.Require().NoError(err)
+	suite.Equal(apps.ApprovalApply, approval)
+	suite.NotContains(out.String(), "Apply changes")
+}
+
+func TestApproveTestSuite(t *testing.T) {
+	suite.Run(t, &ApproveTestSuite{})
+}
@@ -1,18 +1,24 @@
 package cmd
 
 import (
-	"github.com/renehernandez/appfile/internal/errors"
+	"github.com/renehernandez/appfile/internal/apps"
 	"github.com/spf13/cobra"
 )
 
 type destroyCmd struct {
 	*rootCmd
+
+	adopt bool
 }
 
 var (
 	destroyLong = `Destroy apps running in DigitalOcean
 
-It fails without deleting any app if any of the apps declared in the appfile spec is not found in DigitalOcean
+It fails without deleting any app if any of the apps declared in the appfile spec is not found in DigitalOcean,
+or if any of them was not created by the current environment and --adopt is not passed
+
+On Ctrl-C, no new app is destroyed while the in-flight operation finishes, then a summary of the
+destroyed apps is printed. A second Ctrl-C cancels the in-flight operation.
 `
 
 	destroyExample = `  # Destroy using defaults: appfile.yaml in current location, default environment and DIGITALOCEAN_ACCESS_TOKEN env var
@@ -35,16 +41,36 @@ func newDestroyCmd(rootCmd *rootCmd) *cobra.Command {
 		Short:   "Destroy apps running in DigitalOcean",
 		Long:    destroyLong,
 		Example: destroyExample,
-		Run: func(cmd *cobra.Command, args []string) {
-			destroy.run()
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return destroy.run()
 		},
 	}
+
+	cmd.Flags().BoolVar(&destroy.adopt, "adopt", false, "destroy apps that were not created by the environment")
+
 	return cmd
 }
 
-func (destroy *destroyCmd) run() {
-	appfile := destroy.appfileFromSpec()
+func (destroy *destroyCmd) run() error {
+	appfile, err := destroy.appfileFromSpec()
+	if err != nil {
+		return err
+	}
+
+	if err := destroy.loadAppliedState(appfile); err != nil {
+		return err
+	}
+
+	ctx, cancel := destroy.newContext()
+	defer cancel()
+
+	summary, err := appfile.Destroy(ctx, apps.DestroyOptions{
+		Adopt: destroy.adopt,
+	})
+	err = destroy.saveAppliedState(appfile, err)
+	if summary.Interrupted || ctx.Err() != nil {
+		printSummary(summary)
+	}
 
-	err := appfile.Destroy()
-	errors.CheckAndFail(err)
+	return err
 }
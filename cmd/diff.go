@@ -1,20 +1,44 @@
 package cmd
 
 import (
+	"encoding/json"
 	"fmt"
 
-	"github.com/renehernandez/appfile/internal/errors"
+	"github.com/gosuri/uitable"
+	"github.com/pkg/errors"
+	"github.com/renehernandez/appfile/internal/apps"
+	apperrors "github.com/renehernandez/appfile/internal/errors"
 	"github.com/sergi/go-diff/diffmatchpatch"
 	"github.com/spf13/cobra"
 )
 
+// outputText renders the diffs as text
+const outputText = "text"
+
 type diffCmd struct {
 	*rootCmd
+
+	threeWay bool
+	output   string
+}
+
+type appDiffJSON struct {
+	Name string `json:"name"`
+	Diff string `json:"diff"`
 }
 
 var (
 	diffLong = `Diff local app spec against app spec running in DigitalOcean
 
+With --three-way, the spec of every app as it was last synced, read from the state file, is
+compared with the spec running in DigitalOcean and the local spec. Every field that differs
+between DigitalOcean and the local spec is classified as:
+
+  local     changed in the appfile since the last sync
+  drift     changed in DigitalOcean since the last sync
+  conflict  changed both in the appfile and in DigitalOcean
+
+Apps that were never synced with the state file report every difference as a local change.
 `
 	diffExample = `  # Diff using defaults: appfile.yaml in current location, default environment and DIGITALOCEAN_ACCESS_TOKEN env var
 appfile diff
@@ -22,6 +46,9 @@ appfile diff
   # Diff using appfile.yaml in custom location, review environment and access token option
   appfile diff --file /path/to/appfile.yaml --environment review --access-token $TOKEN
 
+  # Classify the differences as local changes, remote drift or conflicts, as JSON
+  appfile diff --three-way --output json
+
   # Diff with debug output
   appfile sync --log-level debug`
 )
@@ -36,26 +63,123 @@ func newDiffCmd(rootCmd *rootCmd) *cobra.Command {
 		Short:   "Diff local app spec against app spec running in DigitalOcean",
 		Long:    diffLong,
 		Example: diffExample,
-		Run: func(cmd *cobra.Command, args []string) {
-			diff.run()
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return diff.run()
 		},
 	}
+
+	cmd.Flags().BoolVar(&diff.threeWay, "three-way", false, "compare the specs as they were last synced, running in DigitalOcean and local")
+	cmd.Flags().StringVarP(&diff.output, "output", "o", outputText, fmt.Sprintf("format of the diff: %s or %s", outputText, outputJSON))
+
 	return cmd
 }
 
-func (diff *diffCmd) run() {
-	appfile := diff.appfileFromSpec()
+func (diff *diffCmd) run() error {
+	if diff.output != outputText && diff.output != outputJSON {
+		return apperrors.New(apperrors.KindConfig, "Unknown output format %s, expected %s or %s", diff.output, outputText, outputJSON)
+	}
+
+	appfile, err := diff.appfileFromSpec()
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := diff.newContext()
+	defer cancel()
+
+	if diff.threeWay {
+		if err := diff.loadAppliedState(appfile); err != nil {
+			return err
+		}
+
+		diffs, err := appfile.ThreeWayDiff(ctx)
+		if err != nil {
+			return err
+		}
+
+		if diff.output == outputJSON {
+			return printJSON(diffs)
+		}
+
+		printThreeWayDiffs(diffs)
+
+		return nil
+	}
+
+	diffs, err := appfile.Diff(ctx)
+	if err != nil {
+		return err
+	}
+
+	if diff.output == outputJSON {
+		output := []appDiffJSON{}
+		for _, appDiff := range diffs {
+			changes, err := appDiff.Changes()
+			if err != nil {
+				return errors.Wrapf(err, "Failed to calculate diff for app %s", appDiff.Name)
+			}
+			output = append(output, appDiffJSON{Name: appDiff.Name, Diff: changes})
+		}
 
-	diffs, err := appfile.Diff()
-	errors.CheckAndFail(err)
+		return printJSON(output)
+	}
 
 	dmp := diffmatchpatch.New()
 
 	for _, appDiff := range diffs {
 		appSpecDiffs, err := appDiff.CalculateDiff()
-		errors.CheckAndFailf(err, "Failed to calculate diff for app %s", appDiff.Name)
+		if err != nil {
+			return errors.Wrapf(err, "Failed to calculate diff for app %s", appDiff.Name)
+		}
 
 		fmt.Printf("Diff for app %s\n", appDiff.Name)
 		fmt.Println(dmp.DiffPrettyText(appSpecDiffs))
 	}
+
+	return nil
+}
+
+func printThreeWayDiffs(diffs []*apps.ThreeWayDiff) {
+	for _, appDiff := range diffs {
+		fmt.Printf("Three-way diff for app %s\n", appDiff.Name)
+		if !appDiff.Recorded {
+			fmt.Println("App was never synced with the state file, differences are shown as local changes")
+		}
+
+		if len(appDiff.Changes) == 0 {
+			fmt.Println("No changes")
+			fmt.Println()
+			continue
+		}
+
+		table := uitable.New()
+		table.Wrap = true
+		table.MaxColWidth = 60
+
+		table.AddRow("CHANGE", "FIELD", "LAST SYNCED", "LIVE", "LOCAL")
+		for _, change := range appDiff.Changes {
+			table.AddRow(change.Kind, change.Path, diffValue(change.LastApplied), diffValue(change.Live), diffValue(change.Desired))
+		}
+		fmt.Println(table)
+		fmt.Println()
+	}
+}
+
+func diffValue(value interface{}) string {
+	if value == nil {
+		return "-"
+	}
+
+	return fmt.Sprint(value)
+}
+
+func printJSON(value interface{}) error {
+	content, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(string(content))
+
+	return nil
 }
@@ -1,6 +1,11 @@
 package cmd
 
 import (
+	"fmt"
+	"strings"
+
+	"github.com/goccy/go-yaml"
+	"github.com/renehernandez/appfile/internal/apps"
 	"github.com/renehernandez/appfile/internal/errors"
 	"github.com/renehernandez/appfile/internal/log"
 	"github.com/spf13/cobra"
@@ -8,11 +13,20 @@ import (
 
 type lintCmd struct {
 	*rootCmd
+
+	maxCost         float32
+	maxCostIncrease float32
+	showSpec        bool
 }
 
 var (
 	lintLong = `Lint the apps specifications against the App Specification Reference.
 
+The valid specs are proposed to DigitalOcean, which reports whether the app name is available and
+the monthly cost of the app, compared with the cost of the app running in DigitalOcean. Lint fails
+when the name is taken by another app or team, or when the cost is above --max-cost or increases
+by more than --max-cost-increase. With --show-spec, the spec normalized by DigitalOcean is printed.
+
 For more details, check the Reference at https://www.digitalocean.com/docs/app-platform/references/app-specification-reference/
 `
 
@@ -22,6 +36,9 @@ appfile lint
   # Lint using appfile.yaml in custom location, review environment and access token option
   appfile lint --file /path/to/appfile.yaml --environment review --access-token $TOKEN
 
+  # Fail if any app costs more than $50/mo or $10/mo more than the app running in DigitalOcean
+  appfile lint --max-cost 50 --max-cost-increase 10
+
   # Lint with debug output
   appfile lint --log-level debug`
 )
@@ -36,26 +53,98 @@ func newLintCmd(rootCmd *rootCmd) *cobra.Command {
 		Short:   "Lint the apps definitions against the App Specification Reference",
 		Long:    lintLong,
 		Example: lintExample,
-		Run: func(cmd *cobra.Command, args []string) {
-			lint.run()
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return lint.run(cmd.Flags().Changed("max-cost"), cmd.Flags().Changed("max-cost-increase"))
 		},
 	}
+
+	cmd.Flags().Float32Var(&lint.maxCost, "max-cost", 0, "fail if an app costs more than the given USD per month")
+	cmd.Flags().Float32Var(&lint.maxCostIncrease, "max-cost-increase", 0, "fail if the cost of an app increases by more than the given USD per month")
+	cmd.Flags().BoolVar(&lint.showSpec, "show-spec", false, "print the spec of every app normalized by DigitalOcean")
+
 	return cmd
 }
 
-func (lint *lintCmd) run() {
-	appfile := lint.appfileFromSpec()
+func (lint *lintCmd) run(maxCostSet bool, maxCostIncreaseSet bool) error {
+	appfile, err := lint.appfileFromSpec()
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := lint.newContext()
+	defer cancel()
 
-	lints, err := appfile.Lint()
-	errors.CheckAndFail(err)
+	opts := apps.LintOptions{}
+	if maxCostSet {
+		opts.MaxCost = &lint.maxCost
+	}
+	if maxCostIncreaseSet {
+		opts.MaxCostIncrease = &lint.maxCostIncrease
+	}
+
+	lints, err := appfile.Lint(ctx, opts)
+	if err != nil {
+		return err
+	}
+
+	failed := []string{}
+	for _, appLint := range lints {
+		logger := log.With(log.Fields{log.FieldApp: appLint.Name, log.FieldOperation: "lint", "file": appLint.FileName})
+		if appLint.Proposal != nil {
+			logProposal(logger, appLint)
+
+			if lint.showSpec {
+				if err := printNormalizedSpec(appLint); err != nil {
+					return err
+				}
+			}
+		}
 
-	for _, lint := range lints {
-		if len(lint.Errors) == 0 {
-			log.Infof("[%s] lint ran successfully", lint.FileName)
+		if len(appLint.Errors) == 0 {
+			logger.Resultf("Lint ran successfully")
 		} else {
-			for _, err := range lint.Errors {
-				log.Errorf("[%s] %s", lint.FileName, err)
+			for _, err := range appLint.Errors {
+				logger.Errorf("%s", err)
 			}
+			failed = append(failed, appLint.Name)
 		}
 	}
+
+	if len(failed) > 0 {
+		return errors.New(errors.KindValidation, "Lint failed for apps: %s", strings.Join(failed, ", "))
+	}
+
+	return nil
+}
+
+// logProposal reports the cost of the app returned by DigitalOcean
+func logProposal(logger *log.Logger, appLint apps.AppLint) {
+	proposal := appLint.Proposal
+
+	if appLint.CurrentCost == nil {
+		logger.Resultf("App costs $%.2f/mo", proposal.AppCost)
+	} else {
+		change := proposal.AppCost - *appLint.CurrentCost
+		sign := "+"
+		if change < 0 {
+			sign = "-"
+			change = -change
+		}
+		logger.Resultf("App costs $%.2f/mo, %s$%.2f vs current", proposal.AppCost, sign, change)
+	}
+
+	if proposal.AppIsStatic {
+		logger.Resultf("App is static. The account has %s static apps, the first %s are free of charge", proposal.ExistingStaticApps, proposal.MaxFreeStaticApps)
+	}
+}
+
+func printNormalizedSpec(appLint apps.AppLint) error {
+	content, err := yaml.Marshal(appLint.Proposal.Spec)
+	if err != nil {
+		return fmt.Errorf("Failed to serialize normalized spec of app %s: %s", appLint.Name, err)
+	}
+
+	fmt.Printf("Normalized spec of app %s\n%s\n", appLint.Name, content)
+
+	return nil
 }
@@ -0,0 +1,98 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/gosuri/uitable"
+	"github.com/renehernandez/appfile/internal/apps"
+	"github.com/spf13/cobra"
+)
+
+type planCmd struct {
+	*rootCmd
+
+	out     string
+	prune   bool
+	adopt   bool
+	force   bool
+	onDrift string
+}
+
+var (
+	planLong = `Record the changes that sync would apply to DigitalOcean in a plan file
+
+The plan holds the rendered app specs, together with the IDs and the spec fingerprints
+of the existing apps, so that it can be reviewed and executed later with apply.
+`
+	planExample = `  # Print the changes that sync would apply
+appfile plan
+
+  # Record the changes of the review environment, including pruned apps, in plan.json
+  appfile plan --environment review --prune --out plan.json`
+)
+
+func newPlanCmd(rootCmd *rootCmd) *cobra.Command {
+	plan := planCmd{
+		rootCmd: rootCmd,
+	}
+
+	cmd := &cobra.Command{
+		Use:     "plan",
+		Short:   "Record the changes that sync would apply in a plan file",
+		Long:    planLong,
+		Example: planExample,
+		Args:    cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return plan.run()
+		},
+	}
+
+	cmd.Flags().StringVar(&plan.out, "out", "", "write the plan to file")
+	cmd.Flags().BoolVar(&plan.prune, "prune", false, "destroy apps of the environment that are no longer declared in the appfile")
+	cmd.Flags().BoolVar(&plan.adopt, "adopt", false, "update existing apps that were not created by the environment")
+	cmd.Flags().BoolVar(&plan.force, "force", false, "update existing apps even if their spec didn't change")
+	cmd.Flags().StringVar(&plan.onDrift, "on-drift", apps.DriftFail, fmt.Sprintf("policy on apps changed in DigitalOcean since they were last synced: %s, %s or %s", apps.DriftFail, apps.DriftWarn, apps.DriftOverwrite))
+
+	return cmd
+}
+
+func (plan *planCmd) run() error {
+	if err := validateDriftPolicy(plan.onDrift); err != nil {
+		return err
+	}
+
+	appfile, err := plan.appfileFromSpec()
+	if err != nil {
+		return err
+	}
+
+	if err := plan.loadAppliedState(appfile); err != nil {
+		return err
+	}
+
+	ctx, cancel := plan.newContext()
+	defer cancel()
+
+	appsPlan, err := appfile.Plan(ctx, apps.PlanOptions{
+		Prune:   plan.prune,
+		Adopt:   plan.adopt,
+		Force:   plan.force,
+		OnDrift: plan.onDrift,
+	})
+	if err != nil {
+		return err
+	}
+
+	table := uitable.New()
+	table.AddRow("NAME", "ACTION")
+	for _, planned := range appsPlan.Apps {
+		table.AddRow(planned.Name, planned.Action)
+	}
+	fmt.Println(table)
+
+	if plan.out == "" {
+		return nil
+	}
+
+	return appsPlan.Write(plan.out)
+}
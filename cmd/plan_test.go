@@ -0,0 +1,40 @@
+package cmd
+
+import (
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type PlanTestSuite struct {
+	suite.Suite
+}
+
+func (suite *PlanTestSuite) TestRejectsSingleDashOut() {
+	cmd := NewRootCmd()
+	cmd.SetOut(ioutil.Discard)
+	cmd.SetErr(ioutil.Discard)
+	cmd.SetArgs([]string{"plan", "-out", "plan.json"})
+
+	err := cmd.Execute()
+
+	suite.Error(err)
+	suite.Contains(err.Error(), "unknown shorthand flag")
+}
+
+func (suite *PlanTestSuite) TestRejectsArguments() {
+	cmd := NewRootCmd()
+	cmd.SetOut(ioutil.Discard)
+	cmd.SetErr(ioutil.Discard)
+	cmd.SetArgs([]string{"plan", "plan.json"})
+
+	err := cmd.Execute()
+
+	suite.Error(err)
+	suite.Contains(err.Error(), "unknown command")
+}
+
+func TestPlanTestSuite(t *testing.T) {
+	suite.Run(t, &PlanTestSuite{})
+}
@@ -1,16 +1,23 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
 	"os"
+	"path/filepath"
+	"time"
 
 	"github.com/joho/godotenv"
 	"github.com/renehernandez/appfile/internal/apps"
+	"github.com/renehernandez/appfile/internal/auth"
+	"github.com/renehernandez/appfile/internal/do"
+	"github.com/renehernandez/appfile/internal/do/fake"
 	"github.com/renehernandez/appfile/internal/errors"
+	"github.com/renehernandez/appfile/internal/interrupt"
 	"github.com/renehernandez/appfile/internal/log"
+	"github.com/renehernandez/appfile/internal/schema"
 	"github.com/renehernandez/appfile/internal/tmpl"
 	"github.com/renehernandez/appfile/internal/version"
-	"github.com/renehernandez/appfile/internal/yaml"
 	"github.com/spf13/cobra"
 )
 
@@ -30,12 +37,37 @@ func (error configureAccessTokenError) Error() string {
 	return error.message
 }
 
+const (
+	backendAPI  = "api"
+	backendFake = "fake"
+
+	// fakeBackendStateVar names the file keeping the state of the fake backend between runs
+	fakeBackendStateVar = "APPFILE_FAKE_BACKEND_STATE"
+)
+
 type rootCmd struct {
-	environment string
-	file        string
-	logLevel    string
-	accessToken string
-	envFile     string
+	environment  string
+	file         string
+	logLevel     string
+	logFormat    string
+	logFile      string
+	quiet        bool
+	accessToken  string
+	tokenFile    string
+	doctlContext string
+	doctlConfig  string
+	envFile      string
+	backend      string
+	stateFile    string
+	// jsonOutput is set when the command prints JSON to the standard output
+	jsonOutput bool
+
+	maxRetries         int
+	retryNonIdempotent bool
+	timeout            time.Duration
+	apiURL             string
+	caFile             string
+	insecureSkipVerify bool
 }
 
 func (root *rootCmd) Environment() string {
@@ -50,10 +82,59 @@ func (root *rootCmd) LogLevel() string {
 	return root.logLevel
 }
 
+// LogOptions returns the configuration of the log output. The logs go to the
+// standard error when the command prints JSON, to keep the output parseable
+func (root *rootCmd) LogOptions() log.Options {
+	return log.Options{
+		Level:  root.logLevel,
+		Format: root.logFormat,
+		File:   root.logFile,
+		Quiet:  root.quiet,
+		Stderr: root.jsonOutput,
+	}
+}
+
 func (root *rootCmd) AccessToken() string {
 	return root.accessToken
 }
 
+func (root *rootCmd) Backend() (do.Backend, error) {
+	switch root.backend {
+	case backendAPI:
+		opts := do.DefaultClientOptions()
+		opts.Retry.MaxRetries = root.maxRetries
+		opts.Retry.RetryNonIdempotent = root.retryNonIdempotent
+		opts.APIURL = root.apiURL
+		opts.CAFile = root.caFile
+		opts.InsecureSkipVerify = root.insecureSkipVerify
+
+		return do.NewBackend(opts)
+	case backendFake:
+		if file, ok := os.LookupEnv(fakeBackendStateVar); ok && file != "" {
+			log.Debugf("Using fake backend with state from %s", file)
+			return fake.NewBackendFromFile(file)
+		}
+
+		log.Debugln("Using in-memory fake backend")
+		return fake.NewBackend(), nil
+	}
+
+	return nil, fmt.Errorf("Unknown backend %s. Must be one of %s or %s", root.backend, backendAPI, backendFake)
+}
+
+// Execute runs the command line and returns its exit code, which depends on the
+// kind of the error, if any
+func Execute() int {
+	_ = log.Initialize(log.Options{Level: "info"})
+
+	if err := NewRootCmd().Execute(); err != nil {
+		log.Errorln(err.Error())
+		return errors.ExitCode(err)
+	}
+
+	return errors.ExitOK
+}
+
 func NewRootCmd() *cobra.Command {
 	root := rootCmd{}
 
@@ -61,38 +142,66 @@ func NewRootCmd() *cobra.Command {
 		Use:     "appfile",
 		Short:   "Deploy app platform specifications to DigitalOcean",
 		Version: version.Version,
-		PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
 			if cmd.Name() == "help" {
-				return
+				return nil
 			}
-			if err := root.initialize(); err != nil {
-				log.Fatalln(err.Error())
+			cmd.SilenceUsage = true
+
+			if output := cmd.Flags().Lookup("output"); output != nil && output.Value.String() == outputJSON {
+				root.jsonOutput = true
 			}
+
+			return errors.Wrap(errors.KindConfig, root.initialize())
 		},
+		SilenceErrors: true,
 	}
+	cmd.SetFlagErrorFunc(func(cmd *cobra.Command, err error) error {
+		return errors.Wrap(errors.KindConfig, err)
+	})
 
 	cmd.PersistentFlags().StringVarP(&root.environment, "environment", "e", "default", "specify the environment name")
 	cmd.PersistentFlags().StringVarP(&root.file, "file", "f", "appfile.yaml", "load appfile spec from file")
 	cmd.PersistentFlags().StringVar(&root.logLevel, "log-level", "info", "set log level")
+	cmd.PersistentFlags().StringVar(&root.logFormat, "log-format", log.FormatConsole, fmt.Sprintf("set log format: %s or %s", log.FormatConsole, log.FormatJSON))
+	cmd.PersistentFlags().StringVar(&root.logFile, "log-file", "", "write the logs to file instead of the standard output")
+	cmd.PersistentFlags().BoolVarP(&root.quiet, "quiet", "q", false, "only print errors and results")
 	cmd.PersistentFlags().StringVarP(&root.accessToken, "access-token", "t", "", "API V2 access token")
+	cmd.PersistentFlags().StringVar(&root.tokenFile, "token-file", "", "read the API V2 access token from file")
+	cmd.PersistentFlags().StringVar(&root.doctlContext, "context", "", "use the access token of the doctl auth context")
+	cmd.PersistentFlags().StringVar(&root.doctlConfig, "doctl-config", "", "path to the doctl configuration file (defaults to the doctl location)")
 	cmd.PersistentFlags().StringVar(&root.envFile, "env-file", ".env", "path to env file")
+	cmd.PersistentFlags().StringVar(&root.backend, "backend", backendAPI, fmt.Sprintf("backend managing the apps: %s or %s. The fake backend keeps its state in the file set by %s", backendAPI, backendFake, fakeBackendStateVar))
+	_ = cmd.PersistentFlags().MarkHidden("backend")
+	cmd.PersistentFlags().StringVar(&root.stateFile, "state-file", "", fmt.Sprintf("file recording the apps as they were last synced (defaults to %s next to the appfile)", apps.DefaultAppliedStateFile))
+	cmd.PersistentFlags().IntVar(&root.maxRetries, "max-retries", do.DefaultMaxRetries, "maximum number of retries of failed API requests")
+	cmd.PersistentFlags().StringVar(&root.apiURL, "api-url", "", "override the DigitalOcean API endpoint")
+	cmd.PersistentFlags().StringVar(&root.caFile, "ca-file", "", "PEM file with certificate authorities to trust when connecting to the API")
+	cmd.PersistentFlags().BoolVar(&root.insecureSkipVerify, "insecure-skip-verify", false, "skip the verification of the API server certificates")
+	cmd.PersistentFlags().DurationVar(&root.timeout, "timeout", 0, "maximum duration of the command, e.g. 10m. Zero means no timeout")
+	cmd.PersistentFlags().BoolVar(&root.retryNonIdempotent, "retry-non-idempotent", false, "also retry failed requests creating resources, which may have been applied")
 	cmd.AddCommand(newDiffCmd(&root))
 	cmd.AddCommand(newSyncCmd(&root))
+	cmd.AddCommand(newPlanCmd(&root))
+	cmd.AddCommand(newApplyCmd(&root))
 	cmd.AddCommand(newDestroyCmd(&root))
 	cmd.AddCommand(newStatusCmd(&root))
 	cmd.AddCommand(newLintCmd(&root))
+	cmd.AddCommand(newSchemaCmd(&root))
 
 	return cmd
 }
 
 func (root *rootCmd) initialize() error {
-	log.Initialize(root.LogLevel())
+	if err := log.Initialize(root.LogOptions()); err != nil {
+		return err
+	}
 
 	if err := root.loadEnvVars(); err != nil {
 		log.Debugln(err.Error())
 	}
 
-	return root.verifyAccessToken()
+	return nil
 }
 
 func (root *rootCmd) loadEnvVars() error {
@@ -105,52 +214,149 @@ func (root *rootCmd) loadEnvVars() error {
 	return nil
 }
 
-func (root *rootCmd) verifyAccessToken() error {
-	if root.AccessToken() == "" {
-		token, ok := os.LookupEnv("DIGITALOCEAN_ACCESS_TOKEN")
-		if !ok || token == "" {
-			return &configureAccessTokenError{
-				message: "No access token option specified and DIGITALOCEAN_ACCESS_TOKEN environment variable is not defined",
-			}
+// resolveAccessToken returns the access token from, in order of precedence, the
+// --access-token, --token-file and --context options, the DIGITALOCEAN_ACCESS_TOKEN
+// environment variable and the token command of the appfile. The doctl tokens
+// are only used for an explicit --context, so that apps are never changed in
+// the account of the current doctl context by accident
+func (root *rootCmd) resolveAccessToken(tokenCommand *auth.Command) (string, error) {
+	switch {
+	case root.accessToken != "":
+		return root.accessToken, nil
+	case root.tokenFile != "":
+		return auth.FromFile(root.tokenFile)
+	case root.doctlContext != "":
+		configFile, err := root.doctlConfigFile()
+		if err != nil {
+			return "", err
 		}
 
-		root.accessToken = token
+		return auth.FromDoctl(configFile, root.doctlContext)
 	}
 
-	return nil
+	if token, ok := os.LookupEnv("DIGITALOCEAN_ACCESS_TOKEN"); ok && token != "" {
+		return token, nil
+	}
+
+	if tokenCommand != nil {
+		return tokenCommand.Token()
+	}
+
+	return "", errors.Wrap(errors.KindAuth, &configureAccessTokenError{
+		message: "No access token found. Use the --access-token, --token-file or --context options, the DIGITALOCEAN_ACCESS_TOKEN environment variable or a tokenCommand in the appfile spec",
+	})
+}
+
+func (root *rootCmd) doctlConfigFile() (string, error) {
+	if root.doctlConfig != "" {
+		return root.doctlConfig, nil
+	}
+
+	return auth.DoctlConfigFile()
+}
+
+// tokenResolver returns the resolver of the access tokens for the apps. The fake
+// backend doesn't need a default token
+func (root *rootCmd) tokenResolver(tokenCommand *auth.Command) *auth.Resolver {
+	if root.backend == backendFake {
+		return auth.NewStaticResolver("")
+	}
+
+	return &auth.Resolver{
+		Default: func() (string, error) {
+			return root.resolveAccessToken(tokenCommand)
+		},
+		DoctlConfig: root.doctlConfig,
+	}
+}
+
+// newContext returns the context for the operations of a command, cancelled
+// after the timeout or on a second interrupt. The first interrupt only stops
+// starting new operations
+func (root *rootCmd) newContext() (context.Context, func()) {
+	ctx, release := interrupt.Notify(context.Background())
+	if root.timeout <= 0 {
+		return ctx, release
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, root.timeout)
+
+	return ctx, func() {
+		cancel()
+		release()
+	}
 }
 
 func (root *rootCmd) logOptions(cmd *cobra.Command) {
-	log.Debugf("Invoking %s command with options: environment=%s; file=%s; log-level=%s", cmd.Name(), root.Environment(), root.File(), root.LogLevel())
+	log.Debugf("Invoking %s command with options: environment=%s; file=%s; log-level=%s; log-format=%s", cmd.Name(), root.Environment(), root.File(), root.LogLevel(), root.logFormat)
 }
 
-func (root *rootCmd) appfileFromSpec() *apps.Appfile {
+// appfileFromSpec loads the appfile from the file set in the options. Errors
+// not classified while loading are reported as config errors
+func (root *rootCmd) appfileFromSpec() (*apps.Appfile, error) {
+	appfile, err := root.loadAppfile()
+
+	return appfile, errors.Classify(errors.KindConfig, err)
+}
+
+func (root *rootCmd) loadAppfile() (*apps.Appfile, error) {
 	log.Debugln("Start parsing appfile spec")
 	templatedYaml, err := tmpl.RenderFromFile(root.File())
-	errors.CheckAndFail(err)
+	if err != nil {
+		return nil, err
+	}
 
-	var spec apps.AppfileSpec
-	err = yaml.ParseAppfileSpec(templatedYaml, &spec)
-	var appfile *apps.Appfile
+	backend, err := root.Backend()
+	if err != nil {
+		return nil, err
+	}
+
+	if schema.Detect(templatedYaml.Bytes()) == schema.AppSpec {
+		log.Debugf("File %s does not declare an appfile spec, parsing it as an app specification", root.File())
+
+		appSpec, err := apps.ParseAppSpec(templatedYaml, root.File())
+		if err != nil {
+			return nil, err
+		}
 
-	if err != nil || !spec.IsValid() {
-		log.Debugf("Could not parse appfile specification from file %s", root.File())
-		log.Debugf("Try parsing app specification instead")
+		return apps.NewAppfileFromAppSpec(appSpec, backend, root.tokenResolver(nil))
+	}
 
-		appSpec := apps.NewAppSpec()
-		err = yaml.ParseAppSpec(templatedYaml, &appSpec)
-		errors.CheckAndFailf(err, "Could not parse app specification from file %s", root.File())
+	spec, err := apps.ParseAppfileSpec(templatedYaml, root.File())
+	if err != nil {
+		return nil, err
+	}
+	log.Debugln("Finished reading appfile spec")
 
-		appfile, err = apps.NewAppfileFromAppSpec(appSpec, root.AccessToken())
-	} else {
-		err = spec.SetPath(root.File())
-		errors.CheckAndFailf(err, "Could not generate absolute path for file %s", root.File())
-		log.Debugln("Finished reading appfile spec")
+	return apps.NewAppfileFromSpec(spec, root.Environment(), backend, root.tokenResolver(spec.TokenCommand))
+}
 
-		appfile, err = apps.NewAppfileFromSpec(&spec, root.Environment(), root.AccessToken())
+// appliedStateFile returns the file recording the apps as they were last synced
+func (root *rootCmd) appliedStateFile() string {
+	if root.stateFile != "" {
+		return root.stateFile
 	}
 
-	errors.CheckAndFail(err)
+	return filepath.Join(filepath.Dir(root.File()), apps.DefaultAppliedStateFile)
+}
+
+// loadAppliedState reads the apps as they were last synced into the appfile
+func (root *rootCmd) loadAppliedState(appfile *apps.Appfile) error {
+	state, err := apps.ReadAppliedState(root.appliedStateFile())
+	if err != nil {
+		return err
+	}
+	appfile.Applied = state
+
+	return nil
+}
+
+// saveAppliedState writes the apps synced by the appfile. It keeps err, the
+// error of the operation, if any
+func (root *rootCmd) saveAppliedState(appfile *apps.Appfile, err error) error {
+	if writeErr := appfile.Applied.Write(root.appliedStateFile()); writeErr != nil && err == nil {
+		return writeErr
+	}
 
-	return appfile
+	return err
 }
@@ -4,16 +4,31 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
-
-	// "os"
-
+	"path/filepath"
 	"testing"
 
+	"github.com/renehernandez/appfile/internal/auth"
+	"github.com/renehernandez/appfile/internal/errors"
 	"github.com/stretchr/testify/suite"
 )
 
 type RootTestSuite struct {
 	suite.Suite
+
+	envToken    string
+	hasEnvToken bool
+}
+
+func (suite *RootTestSuite) SetupTest() {
+	suite.envToken, suite.hasEnvToken = os.LookupEnv("DIGITALOCEAN_ACCESS_TOKEN")
+	os.Unsetenv("DIGITALOCEAN_ACCESS_TOKEN")
+}
+
+func (suite *RootTestSuite) TearDownTest() {
+	os.Unsetenv("DIGITALOCEAN_ACCESS_TOKEN")
+	if suite.hasEnvToken {
+		os.Setenv("DIGITALOCEAN_ACCESS_TOKEN", suite.envToken)
+	}
 }
 
 func (suite *RootTestSuite) TestMissingEnvFileLoadAtDefaultLocation() {
@@ -38,20 +53,106 @@ func (suite *RootTestSuite) TestLoadsEnvFromFilePath() {
 	suite.Equal("WORLD", os.Getenv("HELLO"))
 }
 
-func (suite *RootTestSuite) TestInitializeTokenSetFromDotenvFile() {
-	envFile, err := createTempFile(map[string]string{"DIGITALOCEAN_ACCESS_TOKEN": "TOKEN"})
-	suite.NoError(err)
+func (suite *RootTestSuite) TestResolveAccessTokenFromFlag() {
+	cmd, tokenCommand := suite.tokenSources()
+
+	suite.assertToken("FLAG_TOKEN", cmd, tokenCommand)
+}
+
+func (suite *RootTestSuite) TestResolveAccessTokenFromTokenFile() {
+	cmd, tokenCommand := suite.tokenSources()
+	cmd.accessToken = ""
+
+	suite.assertToken("FILE_TOKEN", cmd, tokenCommand)
+}
+
+func (suite *RootTestSuite) TestResolveAccessTokenFromDoctlContext() {
+	cmd, tokenCommand := suite.tokenSources()
+	cmd.accessToken = ""
+	cmd.tokenFile = ""
+
+	suite.assertToken("CONTEXT_TOKEN", cmd, tokenCommand)
+}
+
+func (suite *RootTestSuite) TestResolveAccessTokenFromEnvVar() {
+	cmd, tokenCommand := suite.tokenSources()
+	cmd.accessToken = ""
+	cmd.tokenFile = ""
+	cmd.doctlContext = ""
+
+	suite.assertToken("ENV_TOKEN", cmd, tokenCommand)
+}
+
+func (suite *RootTestSuite) TestResolveAccessTokenFromDotenvFile() {
+	envFile, err := createTempFile(map[string]string{"DIGITALOCEAN_ACCESS_TOKEN": "DOTENV_TOKEN"})
+	suite.Require().NoError(err)
 	defer os.Remove(envFile)
 
-	cmd := rootCmd{
-		envFile:  envFile,
-		logLevel: "debug",
+	cmd, tokenCommand := suite.tokenSources()
+	os.Unsetenv("DIGITALOCEAN_ACCESS_TOKEN")
+	cmd.accessToken = ""
+	cmd.tokenFile = ""
+	cmd.doctlContext = ""
+	cmd.envFile = envFile
+	cmd.logLevel = "debug"
+
+	suite.Require().NoError(cmd.initialize())
+
+	suite.assertToken("DOTENV_TOKEN", cmd, tokenCommand)
+}
+
+func (suite *RootTestSuite) TestResolveAccessTokenFromTokenCommand() {
+	cmd, tokenCommand := suite.tokenSources()
+	os.Unsetenv("DIGITALOCEAN_ACCESS_TOKEN")
+	cmd.accessToken = ""
+	cmd.tokenFile = ""
+	cmd.doctlContext = ""
+
+	suite.assertToken("COMMAND_TOKEN", cmd, tokenCommand)
+}
+
+func (suite *RootTestSuite) TestResolveAccessTokenIgnoresDoctlCurrentContext() {
+	cmd, _ := suite.tokenSources()
+	os.Unsetenv("DIGITALOCEAN_ACCESS_TOKEN")
+	cmd.accessToken = ""
+	cmd.tokenFile = ""
+	cmd.doctlContext = ""
+
+	_, err := cmd.resolveAccessToken(nil)
+
+	suite.Require().Error(err)
+	suite.Equal(errors.KindAuth, errors.KindOf(err))
+	suite.Contains(err.Error(), "No access token found")
+}
+
+// tokenSources returns the options and the token command setting every
+// source of the access token, each with a distinct token
+func (suite *RootTestSuite) tokenSources() (*rootCmd, *auth.Command) {
+	dir := suite.T().TempDir()
+
+	tokenFile := filepath.Join(dir, "token")
+	suite.Require().NoError(ioutil.WriteFile(tokenFile, []byte("FILE_TOKEN\n"), 0600))
+
+	configFile := filepath.Join(dir, "config.yaml")
+	suite.Require().NoError(ioutil.WriteFile(configFile, []byte("access-token: DEFAULT_TOKEN\nauth-contexts:\n  work: CONTEXT_TOKEN\n"), 0600))
+
+	os.Setenv("DIGITALOCEAN_ACCESS_TOKEN", "ENV_TOKEN")
+
+	cmd := &rootCmd{
+		accessToken:  "FLAG_TOKEN",
+		tokenFile:    tokenFile,
+		doctlContext: "work",
+		doctlConfig:  configFile,
 	}
 
-	err = cmd.initialize()
+	return cmd, &auth.Command{Command: "echo", Args: []string{"COMMAND_TOKEN"}}
+}
 
-	suite.NoError(err)
-	suite.Equal("TOKEN", os.Getenv("DIGITALOCEAN_ACCESS_TOKEN"))
+func (suite *RootTestSuite) assertToken(expected string, cmd *rootCmd, tokenCommand *auth.Command) {
+	token, err := cmd.resolveAccessToken(tokenCommand)
+
+	suite.Require().NoError(err)
+	suite.Equal(expected, token)
 }
 
 func createTempFile(envData map[string]string) (string, error) {
@@ -0,0 +1,62 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/renehernandez/appfile/internal/errors"
+	"github.com/renehernandez/appfile/internal/log"
+	"github.com/renehernandez/appfile/internal/schema"
+	"github.com/spf13/cobra"
+)
+
+type schemaCmd struct {
+	*rootCmd
+}
+
+var (
+	schemaLong = `Print the JSON schema of the appfile spec or the app spec.
+
+The schemas can be used by editors to autocomplete and validate the files.
+appfile validates every file it reads against them.
+`
+	schemaExample = `  # Print the schema for appfile.yaml
+appfile schema appfile
+
+  # Print the schema for app specs
+  appfile schema appspec > appspec.schema.json`
+)
+
+func newSchemaCmd(rootCmd *rootCmd) *cobra.Command {
+	schemaCommand := schemaCmd{
+		rootCmd: rootCmd,
+	}
+
+	cmd := &cobra.Command{
+		Use:       "schema [appfile|appspec]",
+		Short:     "Print the JSON schema of the appfile spec or the app spec",
+		Long:      schemaLong,
+		Example:   schemaExample,
+		Args:      cobra.ExactValidArgs(1),
+		ValidArgs: schema.Names(),
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			cmd.SilenceUsage = true
+
+			return errors.Wrap(errors.KindConfig, log.Initialize(rootCmd.LogOptions()))
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return schemaCommand.run(args[0])
+		},
+	}
+	return cmd
+}
+
+func (schemaCommand *schemaCmd) run(name string) error {
+	content, err := schema.Get(name)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(string(content))
+
+	return nil
+}
@@ -4,7 +4,6 @@ import (
 	"fmt"
 
 	"github.com/gosuri/uitable"
-	"github.com/renehernandez/appfile/internal/errors"
 	"github.com/spf13/cobra"
 )
 
@@ -35,18 +34,26 @@ func newStatusCmd(rootCmd *rootCmd) *cobra.Command {
 		Short:   "Show status for apps defined in the appfile",
 		Long:    statusLong,
 		Example: statusExample,
-		Run: func(cmd *cobra.Command, args []string) {
-			status.run()
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return status.run()
 		},
 	}
 	return cmd
 }
 
-func (status *statusCmd) run() {
-	appfile := status.appfileFromSpec()
+func (status *statusCmd) run() error {
+	appfile, err := status.appfileFromSpec()
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := status.newContext()
+	defer cancel()
 
-	appsStatus, err := appfile.Status()
-	errors.CheckAndFail(err)
+	appsStatus, err := appfile.Status(ctx)
+	if err != nil {
+		return err
+	}
 
 	table := uitable.New()
 	table.Wrap = true
@@ -61,4 +68,6 @@ func (status *statusCmd) run() {
 		table.AddRow("")
 	}
 	fmt.Println(table)
+
+	return nil
 }
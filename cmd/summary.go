@@ -0,0 +1,115 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gosuri/uitable"
+	"github.com/renehernandez/appfile/internal/apps"
+	"github.com/renehernandez/appfile/internal/errors"
+)
+
+const (
+	outputTable = "table"
+	outputJSON  = "json"
+)
+
+// summaryCounts lists the results counted at the end of the summary. The
+// optional ones are only counted when some app has them
+var summaryCounts = []string{
+	apps.ResultCreated,
+	apps.ResultUpdated,
+	apps.ResultUnchanged,
+	apps.ResultDestroyed,
+	apps.ResultFailed,
+	apps.ResultSkipped,
+}
+
+var optionalCounts = map[string]bool{
+	apps.ResultDestroyed: true,
+	apps.ResultSkipped:   true,
+}
+
+type summaryJSON struct {
+	Apps        []appResultJSON `json:"apps"`
+	Counts      map[string]int  `json:"counts"`
+	Interrupted bool            `json:"interrupted"`
+	Aborted     bool            `json:"aborted"`
+}
+
+type appResultJSON struct {
+	Name   string `json:"name"`
+	Result string `json:"result"`
+	Error  string `json:"error,omitempty"`
+}
+
+func validateOutput(output string) error {
+	if output != outputTable && output != outputJSON {
+		return errors.New(errors.KindConfig, "Unknown output format %s, expected %s or %s", output, outputTable, outputJSON)
+	}
+
+	return nil
+}
+
+func printSummary(summary *apps.Summary) {
+	_ = printSummaryAs(summary, outputTable)
+}
+
+func printSummaryAs(summary *apps.Summary, output string) error {
+	if output == outputJSON {
+		return printSummaryJSON(summary)
+	}
+
+	table := uitable.New()
+	table.Wrap = true
+	table.MaxColWidth = 80
+
+	table.AddRow("NAME", "RESULT", "ERROR")
+	for _, appResult := range summary.Results {
+		errMessage := ""
+		if appResult.Err != nil {
+			errMessage = appResult.Err.Error()
+		}
+		table.AddRow(appResult.Name, appResult.Result, errMessage)
+	}
+
+	fmt.Println(table)
+
+	counts := []string{}
+	for _, result := range summaryCounts {
+		count := summary.Count(result)
+		if count == 0 && optionalCounts[result] {
+			continue
+		}
+		counts = append(counts, fmt.Sprintf("%d %s", count, result))
+	}
+	fmt.Printf("\n%s\n", strings.Join(counts, ", "))
+
+	return nil
+}
+
+func printSummaryJSON(summary *apps.Summary) error {
+	output := summaryJSON{
+		Apps:        []appResultJSON{},
+		Counts:      map[string]int{},
+		Interrupted: summary.Interrupted,
+		Aborted:     summary.Aborted,
+	}
+
+	for _, appResult := range summary.Results {
+		result := appResultJSON{
+			Name:   appResult.Name,
+			Result: appResult.Result,
+		}
+		if appResult.Err != nil {
+			result.Error = appResult.Err.Error()
+		}
+		output.Apps = append(output.Apps, result)
+	}
+
+	for _, result := range summaryCounts {
+		output.Counts[result] = summary.Count(result)
+	}
+
+	return printJSON(output)
+}
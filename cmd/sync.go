@@ -1,6 +1,10 @@
 package cmd
 
 import (
+	"fmt"
+	"os"
+
+	"github.com/renehernandez/appfile/internal/apps"
 	"github.com/renehernandez/appfile/internal/errors"
 	"github.com/renehernandez/appfile/internal/log"
 	"github.com/spf13/cobra"
@@ -8,6 +12,15 @@ import (
 
 type syncCmd struct {
 	*rootCmd
+
+	dryRun      bool
+	prune       bool
+	adopt       bool
+	force       bool
+	onDrift     string
+	output      string
+	interactive bool
+	yes         bool
 }
 
 var (
@@ -15,6 +28,28 @@ var (
 
 If there is no app with the existing name, a new app will be create.
 Otherwise the existing app will be updated with the changes in the spec.
+
+Apps created by appfile are marked with the environment they belong to. With --prune,
+apps marked with the current environment that are no longer declared in the appfile are destroyed.
+
+Existing apps that were not created by the current environment are never updated, unless --adopt is passed.
+
+Existing apps whose spec matches the rendered spec are not updated, avoiding redundant deployments,
+unless --force is passed. A summary of the created, updated, unchanged and failed apps is printed
+at the end, as a table or as JSON with --output json.
+
+The apps are recorded as they were last synced in a state file, .appfile-state.json next to the
+appfile by default. Apps to update that were changed in DigitalOcean since, e.g. from the console,
+are reported with a diff of the changes. With --on-drift fail, the default, the sync is refused;
+with warn, the changes are overwritten after reporting them; with overwrite, they are not checked.
+
+With --interactive, the diff of every app to create, update or prune is shown before syncing it,
+asking whether to apply it, skip it or abort the sync. The diffs and the questions are printed
+to the standard error. It requires a terminal on the standard input, unless --yes is passed to
+apply every app after showing its diff.
+
+On Ctrl-C, no new app is synced while the in-flight operation finishes, then a summary of the
+synced apps is printed. A second Ctrl-C cancels the in-flight operation.
 `
 	syncExample = `  # Sync using defaults: appfile.yaml in current location, default environment and DIGITALOCEAN_ACCESS_TOKEN env var
 appfile sync
@@ -22,6 +57,21 @@ appfile sync
   # Sync using appfile.yaml in custom location, review environment and access token option
   appfile sync --file /path/to/appfile.yaml --environment review --access-token $TOKEN
 
+  # Preview which apps would be created, updated and pruned
+  appfile sync --prune --dry-run
+
+  # Redeploy every app, even if its spec didn't change, and print the summary as JSON
+  appfile sync --force --output json 2>sync.log
+
+  # Overwrite the changes made to the apps from the console since the last sync
+  appfile sync --on-drift overwrite
+
+  # Review and approve the changes to every app
+  appfile sync --interactive --prune
+
+  # Fail if the sync takes longer than 15 minutes
+  appfile sync --timeout 15m
+
   # Sync with debug output
   appfile sync --log-level debug`
 )
@@ -36,22 +86,102 @@ func newSyncCmd(rootCmd *rootCmd) *cobra.Command {
 		Short:   "Sync all resources from app platform specs to DigitalOcean",
 		Long:    syncLong,
 		Example: syncExample,
-		Run: func(cmd *cobra.Command, args []string) {
-			sync.run()
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			if sync.yes && !sync.interactive {
+				cmd.SilenceUsage = false
+				return errors.New(errors.KindConfig, "--yes can only be used with --interactive")
+			}
+
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return sync.run()
 		},
 	}
+
+	cmd.Flags().BoolVar(&sync.dryRun, "dry-run", false, "show the changes without applying them")
+	cmd.Flags().BoolVar(&sync.prune, "prune", false, "destroy apps of the environment that are no longer declared in the appfile")
+	cmd.Flags().BoolVar(&sync.adopt, "adopt", false, "update existing apps that were not created by the environment")
+	cmd.Flags().BoolVar(&sync.force, "force", false, "update existing apps even if their spec didn't change")
+	cmd.Flags().StringVar(&sync.onDrift, "on-drift", apps.DriftFail, fmt.Sprintf("policy on apps changed in DigitalOcean since they were last synced: %s, %s or %s", apps.DriftFail, apps.DriftWarn, apps.DriftOverwrite))
+	cmd.Flags().BoolVarP(&sync.interactive, "interactive", "i", false, "show the diff of every app and ask whether to apply it, skip it or abort the sync")
+	cmd.Flags().BoolVarP(&sync.yes, "yes", "y", false, "with --interactive, apply every app without asking")
+	cmd.Flags().StringVarP(&sync.output, "output", "o", outputTable, fmt.Sprintf("format of the summary: %s or %s", outputTable, outputJSON))
+
 	return cmd
 }
 
-func (sync *syncCmd) run() {
-	appfile := sync.appfileFromSpec()
+func (sync *syncCmd) run() error {
+	if err := validateOutput(sync.output); err != nil {
+		return err
+	}
 
-	err := appfile.Sync()
-	errors.CheckAndFail(err)
+	if err := validateDriftPolicy(sync.onDrift); err != nil {
+		return err
+	}
+
+	if sync.interactive && sync.dryRun {
+		return errors.New(errors.KindConfig, "--interactive cannot be used with --dry-run")
+	}
+
+	if sync.interactive && !sync.yes && !stdinIsTerminal() {
+		return errors.New(errors.KindConfig, "--interactive requires a terminal on the standard input. Pass --yes to apply every app without asking")
+	}
+
+	appfile, err := sync.appfileFromSpec()
+	if err != nil {
+		return err
+	}
+
+	if err := sync.loadAppliedState(appfile); err != nil {
+		return err
+	}
+
+	ctx, cancel := sync.newContext()
+	defer cancel()
+
+	opts := apps.SyncOptions{
+		DryRun:  sync.dryRun,
+		Prune:   sync.prune,
+		Adopt:   sync.adopt,
+		Force:   sync.force,
+		OnDrift: sync.onDrift,
+	}
+	if sync.interactive {
+		opts.Approve = newApprover(os.Stdin, os.Stderr, sync.yes).approve
+	}
+
+	summary, err := appfile.Sync(ctx, opts)
+	if !sync.dryRun {
+		err = sync.saveAppliedState(appfile, err)
+	}
+	if !sync.dryRun && (err == nil || len(summary.Results) > 0) {
+		if printErr := printSummaryAs(summary, sync.output); printErr != nil {
+			return printErr
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	if sync.dryRun || sync.output == outputJSON {
+		return nil
+	}
 
 	for _, spec := range appfile.AppSpecs {
 		for _, domain := range spec.Domains {
-			log.Infof("%s app will be accessible at %s", spec.Name, domain.Domain)
+			log.With(log.Fields{log.FieldApp: spec.Name}).Resultf("App will be accessible at %s", domain.Domain)
 		}
 	}
+
+	return nil
+}
+
+func validateDriftPolicy(policy string) error {
+	switch policy {
+	case apps.DriftFail, apps.DriftWarn, apps.DriftOverwrite:
+		return nil
+	}
+
+	return errors.New(errors.KindConfig, "Unknown drift policy %s, expected %s, %s or %s", policy, apps.DriftFail, apps.DriftWarn, apps.DriftOverwrite)
 }
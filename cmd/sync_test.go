@@ -0,0 +1,84 @@
+package cmd
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/renehernandez/appfile/internal/errors"
+	"github.com/stretchr/testify/suite"
+)
+
+type SyncTestSuite struct {
+	suite.Suite
+}
+
+func (suite *SyncTestSuite) TestJSONOutputOnlyPrintsSummaryToStdout() {
+	dir, err := ioutil.TempDir("", "sync-")
+	suite.Require().NoError(err)
+	defer os.RemoveAll(dir)
+
+	stdout, err := captureStdout(func() error {
+		cmd := NewRootCmd()
+		cmd.SetArgs([]string{
+			"sync", "--backend", backendFake,
+			"-f", "../testdata/nested/appfile.yaml", "-e", "review",
+			"--env-file", filepath.Join(dir, ".env"),
+			"--state-file", filepath.Join(dir, "state.json"),
+			"--output", outputJSON,
+		})
+
+		return cmd.Execute()
+	})
+	suite.Require().NoError(err)
+
+	var summary summaryJSON
+	suite.Require().NoError(json.Unmarshal(stdout, &summary), string(stdout))
+	suite.Equal(2, summary.Counts["created"])
+	suite.Len(summary.Apps, 2)
+	suite.False(summary.Interrupted)
+}
+
+func (suite *SyncTestSuite) TestRejectsYesWithoutInteractive() {
+	cmd := NewRootCmd()
+	cmd.SetOut(ioutil.Discard)
+	cmd.SetErr(ioutil.Discard)
+	cmd.SetArgs([]string{"sync", "--backend", backendFake, "-f", "../testdata/nested/appfile.yaml", "--yes"})
+
+	err := cmd.Execute()
+
+	suite.EqualError(err, "--yes can only be used with --interactive")
+	suite.Equal(errors.KindConfig, errors.KindOf(err))
+}
+
+// captureStdout returns what run writes to the standard output
+func captureStdout(run func() error) ([]byte, error) {
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+
+	stdout := os.Stdout
+	os.Stdout = writer
+	defer func() {
+		os.Stdout = stdout
+	}()
+
+	output := make(chan []byte)
+	go func() {
+		content, _ := ioutil.ReadAll(reader)
+		output <- content
+	}()
+
+	runErr := run()
+	writer.Close()
+	os.Stdout = stdout
+
+	return <-output, runErr
+}
+
+func TestSyncTestSuite(t *testing.T) {
+	suite.Run(t, &SyncTestSuite{})
+}
@@ -1,13 +1,19 @@
 package apps
 
 import (
+	"bytes"
 	"fmt"
+	"path/filepath"
 	"regexp"
 	"strings"
 
 	mapset "github.com/deckarep/golang-set"
 	"github.com/digitalocean/godo"
+	"github.com/pkg/errors"
+	"github.com/renehernandez/appfile/internal/auth"
 	"github.com/renehernandez/appfile/internal/log"
+	"github.com/renehernandez/appfile/internal/schema"
+	"github.com/renehernandez/appfile/internal/yaml"
 )
 
 type regexes struct {
@@ -55,8 +61,13 @@ var (
 type AppSpec struct {
 	*godo.AppSpec
 
-	FileName  string
-	validator *specValidator
+	FileName    string
+	filePath    string
+	validator   *specValidator
+	hooks       []*Hook
+	tokenSource *auth.Source
+	// owner is the project and environment of the appfile declaring the app
+	owner Owner
 }
 
 func NewAppSpec() *AppSpec {
@@ -69,6 +80,24 @@ func NewAppSpec() *AppSpec {
 	return spec
 }
 
+// ParseAppSpec validates the rendered app spec read from file against the
+// app spec schema and parses it
+func ParseAppSpec(templatedYaml *bytes.Buffer, file string) (*AppSpec, error) {
+	if err := schema.Validate(schema.AppSpec, templatedYaml.Bytes()); err != nil {
+		return &AppSpec{}, errors.Wrapf(schemaError(err), "Invalid app spec in file %s", file)
+	}
+
+	spec := NewAppSpec()
+	if err := yaml.ParseAppSpec(templatedYaml, spec); err != nil {
+		return &AppSpec{}, errors.Wrapf(err, "Could not parse resulting yaml for app spec from file %s", file)
+	}
+
+	spec.FileName = filepath.Base(file)
+	spec.filePath = file
+
+	return spec, nil
+}
+
 func (spec *AppSpec) SetDefaultValues() {
 	log.Debugf("Setting default values for %s spec", spec.Name)
 	for _, siteSpec := range spec.StaticSites {
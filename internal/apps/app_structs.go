@@ -36,6 +36,12 @@ func (diff *AppDiff) CalculateDiff() ([]diffmatchpatch.Diff, error) {
 	return diffs, nil
 }
 
+// Changes returns the lines of the remote spec removed by the local spec,
+// prefixed with -, and the lines it adds, prefixed with +
+func (diff *AppDiff) Changes() (string, error) {
+	return specChanges(diff.remoteSpec, diff.localSpec)
+}
+
 func appSpecToString(spec *godo.AppSpec) (string, error) {
 	if spec == nil {
 		return "", nil
@@ -69,4 +75,10 @@ type AppLint struct {
 	FileName string
 	Name     string
 	Errors   []error
+	// Proposal is the response of DigitalOcean to the proposal of the spec,
+	// nil if the spec is invalid
+	Proposal *godo.AppProposeResponse
+	// CurrentCost is the monthly cost in USD of the app running in
+	// DigitalOcean, nil if it doesn't exist or its cost is unknown
+	CurrentCost *float32
 }
@@ -1,11 +1,18 @@
 package apps
 
 import (
+	"context"
 	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
 
 	"github.com/digitalocean/godo"
 	"github.com/pkg/errors"
+	"github.com/renehernandez/appfile/internal/auth"
 	"github.com/renehernandez/appfile/internal/do"
+	apperrors "github.com/renehernandez/appfile/internal/errors"
+	"github.com/renehernandez/appfile/internal/interrupt"
 	"github.com/renehernandez/appfile/internal/log"
 )
 
@@ -18,123 +25,711 @@ type StateData struct {
 	Values      map[string]interface{}
 }
 
+// SyncOptions configures how Sync reconciles the declared apps with DigitalOcean
+type SyncOptions struct {
+	// DryRun reports the changes without applying them
+	DryRun bool
+	// Prune destroys the apps managed by the environment that are no longer declared
+	Prune bool
+	// Adopt allows updating apps that were not created by the environment
+	Adopt bool
+	// Force updates the apps whose spec didn't change
+	Force bool
+	// OnDrift is the policy on the apps changed in DigitalOcean since they were
+	// last synced: DriftFail, DriftWarn or DriftOverwrite. Defaults to DriftFail
+	OnDrift string
+	// Approve is asked, when set, whether to sync every app that would be
+	// created, updated or pruned, given the diff of its changes
+	Approve Approver
+}
+
+// Answers of an Approver
+const (
+	// ApprovalApply syncs the app
+	ApprovalApply = "apply"
+	// ApprovalSkip leaves the app as it is and continues with the next one
+	ApprovalSkip = "skip"
+	// ApprovalAbort stops the sync, leaving the app and the remaining ones as they are
+	ApprovalAbort = "abort"
+)
+
+// Approver decides whether to sync an app given the diff of its changes,
+// answering ApprovalApply, ApprovalSkip or ApprovalAbort
+type Approver func(diff *AppDiff) (string, error)
+
+// DestroyOptions configures how Destroy deletes the declared apps from DigitalOcean
+type DestroyOptions struct {
+	// Adopt allows destroying apps that were not created by the environment
+	Adopt bool
+}
+
+// Operations reported in the logs
+const (
+	operationCreate  = "create"
+	operationUpdate  = "update"
+	operationPrune   = "prune"
+	operationDestroy = "destroy"
+	operationStatus  = "status"
+	operationLint    = "lint"
+)
+
 type Appfile struct {
 	Spec     *AppfileSpec
 	AppSpecs []*AppSpec
 	State    *StateData
+	// Project identifies the appfile in the ownership markers of its apps
+	Project string
+	// owners holds the owners of the apps of the appfile and of its nested
+	// appfiles, whose apps can be pruned
+	owners []Owner
+	// Applied records the apps as they were last synced. Changes made to them
+	// out of band are not detected when nil
+	Applied *AppliedState
+
+	// accounts holds the services of every access token used by the apps
+	accounts []*account
+	// appAccounts maps the declared apps to their account
+	appAccounts map[string]*account
+	// remoteAccounts maps the apps read from remote to their account
+	remoteAccounts map[string]*account
+}
 
-	token string
+// account holds the services built with an access token
+type account struct {
+	appSvc    do.AppService
+	domainSvc do.DomainService
 }
 
-func NewAppfileFromAppSpec(spec *AppSpec, token string) (*Appfile, error) {
+func NewAppfileFromAppSpec(spec *AppSpec, backend do.Backend, resolver *auth.Resolver) (*Appfile, error) {
 	spec.SetDefaultValues()
 
-	return &Appfile{
+	state := StateData{
+		Environment: EnvMetadata{
+			Name: "default",
+		},
+	}
+
+	appfile := &Appfile{
 		Spec: &AppfileSpec{},
 		AppSpecs: []*AppSpec{
 			spec,
 		},
-		State: &StateData{},
-		token: token,
-	}, nil
-}
+		State:   &state,
+		Project: defaultProject(spec.filePath),
+	}
+	spec.SetOwner(appfile.owner())
+	appfile.owners = []Owner{appfile.owner()}
 
-func NewAppfileFromSpec(spec *AppfileSpec, envName string, token string) (*Appfile, error) {
-	env, err := spec.ReadEnvironment(envName)
-	if err != nil {
+	if err := appfile.buildAccounts(backend, resolver); err != nil {
 		return &Appfile{}, err
 	}
 
-	state := StateData{
-		Environment: EnvMetadata{
-			Name: env.Name,
-		},
-		Values: env.Values,
-	}
+	return appfile, nil
+}
 
-	appSpecs, err := spec.loadAppSpecs(&state)
+func NewAppfileFromSpec(spec *AppfileSpec, envName string, backend do.Backend, resolver *auth.Resolver) (*Appfile, error) {
+	state, appSpecs, owners, err := spec.load(envName, []*ValuesEntry{}, filepath.Dir(spec.Path()), nil, []string{})
 	if err != nil {
 		return &Appfile{}, err
 	}
 
-	return &Appfile{
+	if err = checkDuplicateNames(appSpecs); err != nil {
+		return &Appfile{}, err
+	}
+
+	appfile := &Appfile{
 		Spec:     spec,
-		State:    &state,
+		State:    state,
 		AppSpecs: appSpecs,
-		token:    token,
-	}, nil
+		Project:  spec.project(),
+		owners:   owners,
+	}
+
+	if err := appfile.buildAccounts(backend, resolver); err != nil {
+		return &Appfile{}, err
+	}
+
+	return appfile, nil
 }
 
-func (appfile *Appfile) Sync() error {
-	remoteApps, err := appfile.readAppsFromRemote()
+// buildAccounts builds the services of every access token used by the apps,
+// resolving each token source once
+func (appfile *Appfile) buildAccounts(backend do.Backend, resolver *auth.Resolver) error {
+	byToken := map[string]*account{}
+	appfile.accounts = []*account{}
+	appfile.appAccounts = map[string]*account{}
+	appfile.remoteAccounts = map[string]*account{}
+
+	accountFor := func(source *auth.Source) (*account, error) {
+		token, err := resolver.Token(source)
+		if err != nil {
+			return nil, err
+		}
+
+		if acc, ok := byToken[token]; ok {
+			return acc, nil
+		}
+
+		acc := &account{
+			appSvc:    backend.AppService(token),
+			domainSvc: backend.DomainService(token),
+		}
+		byToken[token] = acc
+		appfile.accounts = append(appfile.accounts, acc)
+
+		return acc, nil
+	}
+
+	for _, appSpec := range appfile.AppSpecs {
+		if appSpec.tokenSource != nil {
+			log.Debugf("Using access token from %s for app %s", appSpec.tokenSource, appSpec.Name)
+		}
+
+		acc, err := accountFor(appSpec.tokenSource)
+		if err != nil {
+			return errors.Wrapf(err, "Could not configure access to app %s", appSpec.Name)
+		}
+		appfile.appAccounts[appSpec.Name] = acc
+	}
+
+	if len(appfile.accounts) == 0 {
+		if _, err := accountFor(nil); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// accountFor returns the account of the app with the given name, declared or read from remote
+func (appfile *Appfile) accountFor(name string) *account {
+	if acc, ok := appfile.appAccounts[name]; ok {
+		return acc
+	}
+
+	if acc, ok := appfile.remoteAccounts[name]; ok {
+		return acc
+	}
+
+	return appfile.accounts[0]
+}
+
+func (appfile *Appfile) Sync(ctx context.Context, opts SyncOptions) (*Summary, error) {
+	summary := &Summary{}
+
+	remoteApps, err := appfile.readAppsFromRemote(ctx)
 	if err != nil {
-		return err
+		return summary, err
+	}
+
+	for _, appSpec := range appfile.AppSpecs {
+		if remoteApp, ok := remoteApps[appSpec.Name]; ok && !opts.Adopt {
+			if err := appfile.checkOwnership(remoteApp); err != nil {
+				return summary, err
+			}
+		}
 	}
 
-	svc := do.NewAppService(appfile.token)
+	if err := appfile.checkDrift(remoteApps, opts.OnDrift, opts.DryRun); err != nil {
+		return summary, err
+	}
+
+	pruneList := []*godo.App{}
+	if opts.Prune {
+		pruneList = appfile.appsToPrune(remoteApps)
+		for _, app := range pruneList {
+			logger := appfile.appLogger(app.Spec.Name, operationPrune)
+			if opts.DryRun {
+				logger.Infof("App would be pruned")
+			} else {
+				logger.Infof("App is no longer declared and will be pruned")
+			}
+		}
+	}
 
+	if opts.DryRun {
+		for _, appSpec := range appfile.AppSpecs {
+			remoteApp, ok := remoteApps[appSpec.Name]
+			if !ok {
+				appfile.appLogger(appSpec.Name, operationCreate).Infof("App would be created")
+				continue
+			}
+
+			logger := appfile.appLogger(appSpec.Name, operationUpdate)
+			unchanged, err := appfile.unchanged(ctx, appSpec.AppSpec, remoteApp, opts.Force)
+			if err != nil {
+				return summary, err
+			}
+
+			if unchanged {
+				logger.Infof("App is unchanged and would not be updated")
+				summary.add(appSpec.Name, ResultUnchanged, nil)
+			} else {
+				logger.Infof("App would be updated")
+			}
+		}
+
+		return summary, nil
+	}
+
+	specs := []*godo.AppSpec{}
+	for _, appSpec := range appfile.AppSpecs {
+		specs = append(specs, appSpec.AppSpec)
+	}
+
+	return summary, appfile.syncApps(ctx, specs, remoteApps, pruneList, opts, summary)
+}
+
+// unchanged reports whether the remote app already matches the spec, in
+// which case it doesn't need to be updated unless forced. DigitalOcean fills
+// in defaults for the fields the spec leaves empty, so a spec that differs
+// from the remote one is compared again once proposed
+func (appfile *Appfile) unchanged(ctx context.Context, spec *godo.AppSpec, remoteApp *godo.App, force bool) (bool, error) {
+	if force {
+		return false, nil
+	}
+
+	comparison, err := CompareSpecs(spec, remoteApp.Spec)
+	if err != nil {
+		return false, err
+	}
+
+	if !comparison.Equal {
+		proposal, err := appfile.accountFor(spec.Name).appSvc.Propose(ctx, &godo.App{ID: remoteApp.ID, Spec: spec})
+		if err != nil {
+			appfile.appLogger(spec.Name, operationUpdate).Debugf("Failed to propose the app to fill in the defaults of DigitalOcean: %s", err)
+			return false, nil
+		}
+
+		if comparison, err = CompareSpecs(proposal.Spec, remoteApp.Spec); err != nil {
+			return false, err
+		}
+	}
+
+	if comparison.Equal && len(comparison.MaskedSecrets) > 0 {
+		appfile.appLogger(spec.Name, operationUpdate).Infof("Values of the encrypted secrets %s are not compared. Use --force to update them", strings.Join(comparison.MaskedSecrets, ", "))
+	}
+
+	return comparison.Equal, nil
+}
+
+// checkDrift reports the declared apps that changed in DigitalOcean since they
+// were last synced and whose changes would be overwritten. It fails with the
+// DriftFail policy, unless running dry
+func (appfile *Appfile) checkDrift(remoteApps map[string]*godo.App, policy string, dryRun bool) error {
+	if appfile.Applied == nil || policy == DriftOverwrite {
+		return nil
+	}
+
+	drifted := []string{}
 	for _, appSpec := range appfile.AppSpecs {
-		log.Infof("Syncing app %s", appSpec.Name)
 		remoteApp, ok := remoteApps[appSpec.Name]
-		localApp := &godo.App{Spec: appSpec.AppSpec}
 		if !ok {
-			err = svc.Create(localApp)
+			continue
+		}
+
+		comparison, err := CompareSpecs(appSpec.AppSpec, remoteApp.Spec)
+		if err != nil {
+			return err
+		}
+		if comparison.Equal {
+			continue
+		}
+
+		drift, err := appfile.Applied.Drift(appfile.State.Environment.Name, remoteApp)
+		if err != nil {
+			return err
+		}
+		if drift == nil {
+			continue
+		}
+
+		appfile.appLogger(appSpec.Name, operationUpdate).Warningf("App changed in DigitalOcean since it was last synced:\n%s", drift.Changes)
+		drifted = append(drifted, appSpec.Name)
+	}
+
+	if len(drifted) > 0 && policy != DriftWarn && !dryRun {
+		return apperrors.New(apperrors.KindDeployment, "Apps changed in DigitalOcean since they were last synced: %s. Use --on-drift=overwrite to overwrite the changes", strings.Join(drifted, ", "))
+	}
+
+	return nil
+}
+
+// recordApplied records the app synced in the applied state, if any
+func (appfile *Appfile) recordApplied(app *godo.App) error {
+	if appfile.Applied == nil {
+		return nil
+	}
+
+	return appfile.Applied.record(appfile.State.Environment.Name, app)
+}
+
+// syncApps creates or updates the apps with the given specs, running their
+// hooks, and then destroys the apps in pruneList. Existing apps matching
+// their spec are not updated unless forced. The apps to change are synced
+// only if approved
+func (appfile *Appfile) syncApps(ctx context.Context, specs []*godo.AppSpec, remoteApps map[string]*godo.App, pruneList []*godo.App, opts SyncOptions, summary *Summary) error {
+	failed := []string{}
+
+	for i, spec := range specs {
+		remoteApp, ok := remoteApps[spec.Name]
+		operation := operationUpdate
+		if !ok {
+			operation = operationCreate
+		}
+		logger := appfile.appLogger(spec.Name, operation)
+
+		if ok {
+			unchanged, err := appfile.unchanged(ctx, spec, remoteApp, opts.Force)
+			if err != nil {
+				return err
+			}
+
+			if unchanged {
+				logger.Infof("App is unchanged, skipping update")
+				summary.add(spec.Name, ResultUnchanged, nil)
+				if err := appfile.recordApplied(remoteApp); err != nil {
+					return err
+				}
+				continue
+			}
+		}
+
+		if interrupt.Stopped(ctx) {
+			pending := []string{}
+			for _, pendingSpec := range specs[i:] {
+				pending = append(pending, pendingSpec.Name)
+			}
+			summary.skip(pending...)
+			summary.skip(appNames(pruneList)...)
+
+			return fmt.Errorf("Sync interrupted before syncing apps: %s", strings.Join(pending, ", "))
+		}
+
+		diff := &AppDiff{Name: spec.Name, localSpec: spec}
+		if ok {
+			diff.remoteSpec = remoteApp.Spec
+		}
+		approval, err := approve(opts, diff)
+		if err != nil {
+			return err
+		}
+
+		switch approval {
+		case ApprovalSkip:
+			logger.Infof("Skipping sync of app, not approved")
+			summary.add(spec.Name, ResultSkipped, nil)
+			continue
+		case ApprovalAbort:
+			pending := []string{}
+			for _, pendingSpec := range specs[i:] {
+				pending = append(pending, pendingSpec.Name)
+			}
+			summary.abort(pending...)
+			summary.abort(appNames(pruneList)...)
+
+			return apperrors.New(apperrors.KindAborted, "Sync aborted before syncing apps: %s", strings.Join(pending, ", "))
+		}
+
+		hooks := appfile.hooksFor(spec.Name)
+		hookCtx := newHookContext(appfile.State.Environment.Name, spec.Name, remoteApp)
+		if err := runHooks(ctx, hooks, HookEventPreSync, hookCtx); err != nil {
+			logger.Errorf("Skipping sync of app: %s", err)
+			runFailureHooks(ctx, hooks, hookCtx, err)
+			summary.add(spec.Name, ResultFailed, err)
+			failed = append(failed, spec.Name)
+			continue
+		}
+
+		logger.Infof("Syncing app")
+		localApp := &godo.App{Spec: spec}
+		var syncedApp *godo.App
+		result := ResultUpdated
+		if !ok {
+			result = ResultCreated
+			syncedApp, err = appfile.accountFor(spec.Name).appSvc.Create(ctx, localApp)
 		} else {
-			err = svc.Update(localApp, remoteApp)
+			syncedApp, err = appfile.accountFor(spec.Name).appSvc.Update(ctx, localApp, remoteApp)
+		}
+
+		if err != nil {
+			runFailureHooks(ctx, hooks, hookCtx, err)
+			summary.add(spec.Name, ResultFailed, err)
+			return err
+		}
+		logger.With(log.Fields{log.FieldDeploymentID: deploymentID(syncedApp)}).Infof("App synced successfully")
+		summary.add(spec.Name, result, nil)
+		if err := appfile.recordApplied(syncedApp); err != nil {
+			return err
+		}
+
+		if hasHooks(hooks, HookEventPostSync) {
+			logger.Infof("Waiting for the deployment to become active before running the postsync hooks")
+			deployedApp, err := appfile.accountFor(spec.Name).appSvc.WaitForDeployment(ctx, syncedApp)
+			if err != nil {
+				logger.Errorf("Skipping postsync hooks: %s", err)
+				runFailureHooks(ctx, hooks, hookCtx, err)
+				summary.add(spec.Name, ResultFailed, err)
+				failed = append(failed, spec.Name)
+				continue
+			}
+			syncedApp = deployedApp
+		}
+
+		hookCtx = newHookContext(appfile.State.Environment.Name, spec.Name, syncedApp)
+		if err := runHooks(ctx, hooks, HookEventPostSync, hookCtx); err != nil {
+			logger.Errorf("%s", err)
+			runFailureHooks(ctx, hooks, hookCtx, err)
+			summary.add(spec.Name, ResultFailed, err)
+			failed = append(failed, spec.Name)
 		}
+	}
 
+	approved := []*godo.App{}
+	for i, app := range pruneList {
+		approval, err := approve(opts, &AppDiff{Name: app.Spec.Name, remoteSpec: app.Spec})
 		if err != nil {
 			return err
 		}
-		log.Infof("App %s synced successfully", localApp.Spec.Name)
+
+		switch approval {
+		case ApprovalSkip:
+			appfile.appLogger(app.Spec.Name, operationPrune).Infof("Skipping prune of app, not approved")
+			summary.add(app.Spec.Name, ResultSkipped, nil)
+			continue
+		case ApprovalAbort:
+			pending := appNames(pruneList[i:])
+			summary.abort(pending...)
+
+			return apperrors.New(apperrors.KindAborted, "Sync aborted before pruning apps: %s", strings.Join(pending, ", "))
+		}
+
+		approved = append(approved, app)
+	}
+
+	if err := appfile.destroyApps(ctx, approved, summary); err != nil {
+		return err
+	}
+
+	if len(failed) > 0 {
+		return apperrors.New(apperrors.KindDeployment, "Failed to sync apps: %s", strings.Join(failed, ", "))
 	}
 
 	return nil
 }
 
-func (appfile *Appfile) Destroy() error {
-	remoteApps, err := appfile.readAppsFromRemote()
+func (appfile *Appfile) Destroy(ctx context.Context, opts DestroyOptions) (*Summary, error) {
+	summary := &Summary{}
+
+	remoteApps, err := appfile.readAppsFromRemote(ctx)
 	if err != nil {
-		return err
+		return summary, err
 	}
 
-	appSvc := do.NewAppService(appfile.token)
-	domainSvc := do.NewDomainService(appfile.token)
 	remoteList := []*godo.App{}
 
 	for _, appSpec := range appfile.AppSpecs {
 		remoteApp, ok := remoteApps[appSpec.Name]
 		if !ok {
-			return fmt.Errorf("No app to destroy with name %s", appSpec.Name)
+			return summary, apperrors.New(apperrors.KindNotFound, "No app to destroy with name %s", appSpec.Name)
+		}
+
+		if !opts.Adopt {
+			if err := appfile.checkOwnership(remoteApp); err != nil {
+				return summary, err
+			}
 		}
 
 		remoteList = append(remoteList, remoteApp)
 	}
 
-	for _, app := range remoteList {
-		log.Debugf("Destroying app %s", app.Spec.Name)
-		err := appSvc.Destroy(app)
+	return summary, appfile.destroyApps(ctx, remoteList, summary)
+}
+
+func (appfile *Appfile) destroyApps(ctx context.Context, remoteList []*godo.App, summary *Summary) error {
+	failed := []string{}
+
+	for i, app := range remoteList {
+		if interrupt.Stopped(ctx) {
+			pending := appNames(remoteList[i:])
+			summary.skip(pending...)
+
+			return fmt.Errorf("Destroy interrupted before destroying apps: %s", strings.Join(pending, ", "))
+		}
+
+		logger := appfile.appLogger(app.Spec.Name, operationDestroy)
+		hooks := appfile.hooksFor(app.Spec.Name)
+		hookCtx := newHookContext(appfile.State.Environment.Name, app.Spec.Name, app)
+		if err := runHooks(ctx, hooks, HookEventPreDestroy, hookCtx); err != nil {
+			logger.Errorf("Skipping destroy of app: %s", err)
+			runFailureHooks(ctx, hooks, hookCtx, err)
+			summary.add(app.Spec.Name, ResultFailed, err)
+			failed = append(failed, app.Spec.Name)
+			continue
+		}
+
+		logger.Debugf("Destroying app")
+		acc := appfile.accountFor(app.Spec.Name)
+		err := acc.appSvc.Destroy(ctx, app)
 		if err != nil {
+			runFailureHooks(ctx, hooks, hookCtx, err)
+			summary.add(app.Spec.Name, ResultFailed, err)
 			return err
 		}
-		log.Infof("App %s destroyed successfully", app.Spec.Name)
+		logger.Infof("App destroyed successfully")
+		summary.add(app.Spec.Name, ResultDestroyed, nil)
+		if appfile.Applied != nil {
+			appfile.Applied.forget(appfile.State.Environment.Name, app.Spec.Name)
+		}
 
 		for _, domain := range app.Spec.Domains {
 			if domain.Domain != "" && domain.Zone != "" {
-				log.Debugf("Deleting %s hostname in %s zone", domain.Domain, domain.Zone)
-				err = domainSvc.DeleteRecord(domain)
+				logger.Debugf("Deleting %s hostname in %s zone", domain.Domain, domain.Zone)
+				err = acc.domainSvc.DeleteRecord(ctx, domain)
 				if err != nil {
+					runFailureHooks(ctx, hooks, hookCtx, err)
+					summary.add(app.Spec.Name, ResultFailed, err)
 					return err
 				}
 			}
 		}
+
+		if err := runHooks(ctx, hooks, HookEventPostDestroy, hookCtx); err != nil {
+			logger.Errorf("%s", err)
+			runFailureHooks(ctx, hooks, hookCtx, err)
+			summary.add(app.Spec.Name, ResultFailed, err)
+			failed = append(failed, app.Spec.Name)
+		}
+	}
+
+	if len(failed) > 0 {
+		return apperrors.New(apperrors.KindDeployment, "Failed to destroy apps: %s", strings.Join(failed, ", "))
+	}
+
+	return nil
+}
+
+// approve asks the approver of the options whether to sync the app with the
+// given diff. Apps are approved when there is no approver
+func approve(opts SyncOptions, diff *AppDiff) (string, error) {
+	if opts.Approve == nil {
+		return ApprovalApply, nil
+	}
+
+	return opts.Approve(diff)
+}
+
+// appLogger returns the logger of an operation on the app with the given name
+func (appfile *Appfile) appLogger(name string, operation string) *log.Logger {
+	return log.With(log.Fields{
+		log.FieldComponent:   "apps",
+		log.FieldEnvironment: appfile.State.Environment.Name,
+		log.FieldApp:         name,
+		log.FieldOperation:   operation,
+	})
+}
+
+// deploymentID returns the ID of the deployment started for the app, if any
+func deploymentID(app *godo.App) string {
+	switch {
+	case app.InProgressDeployment != nil:
+		return app.InProgressDeployment.ID
+	case app.ActiveDeployment != nil:
+		return app.ActiveDeployment.ID
+	}
+
+	return ""
+}
+
+// hooksFor returns the hooks to run for the app with the given name.
+// Apps not declared in the appfile only run the global hooks
+func (appfile *Appfile) hooksFor(name string) []*Hook {
+	for _, appSpec := range appfile.AppSpecs {
+		if appSpec.Name == name {
+			return appSpec.hooks
+		}
+	}
+
+	return appfile.Spec.globalHooks()
+}
+
+// owner returns the owner of the apps declared by the root appfile
+func (appfile *Appfile) owner() Owner {
+	return Owner{
+		Project:     appfile.Project,
+		Environment: appfile.State.Environment.Name,
+	}
+}
+
+// ownerOf returns the owner of the declared app with the given name, which is
+// the appfile declaring it, possibly a nested one
+func (appfile *Appfile) ownerOf(name string) Owner {
+	for _, appSpec := range appfile.AppSpecs {
+		if appSpec.Name == name {
+			return appSpec.owner
+		}
+	}
+
+	return appfile.owner()
+}
+
+// ownsPrunable returns whether apps of owner not declared anymore can be pruned
+func (appfile *Appfile) ownsPrunable(owner Owner) bool {
+	for _, current := range appfile.owners {
+		if current == owner {
+			return true
+		}
+	}
+
+	return false
+}
+
+// checkOwnership verifies that the remote app was created by the project and
+// environment of the appfile declaring it
+func (appfile *Appfile) checkOwnership(remoteApp *godo.App) error {
+	owner, managed := AppOwner(remoteApp.Spec)
+	current := appfile.ownerOf(remoteApp.Spec.Name)
+
+	switch {
+	case !managed:
+		return apperrors.New(apperrors.KindDeployment, "App %s was not created by appfile. Use --adopt to manage it anyway", remoteApp.Spec.Name)
+	case owner.Project != current.Project:
+		return apperrors.New(apperrors.KindDeployment, "App %s is managed by project %s. Use --adopt to manage it from project %s", remoteApp.Spec.Name, owner.Project, current.Project)
+	case owner.Environment != current.Environment:
+		return apperrors.New(apperrors.KindDeployment, "App %s is managed by environment %s. Use --adopt to manage it from environment %s", remoteApp.Spec.Name, owner.Environment, current.Environment)
 	}
 
 	return nil
 }
 
-func (appfile *Appfile) Diff() ([]*AppDiff, error) {
-	remoteApps, err := appfile.readAppsFromRemote()
+// appsToPrune returns the remote apps owned by the project and environment of
+// the appfile, or of its nested appfiles, that are not declared anymore, sorted
+// by name
+func (appfile *Appfile) appsToPrune(remoteApps map[string]*godo.App) []*godo.App {
+	declared := map[string]bool{}
+	for _, appSpec := range appfile.AppSpecs {
+		declared[appSpec.Name] = true
+	}
+
+	pruneList := []*godo.App{}
+	for name, app := range remoteApps {
+		owner, managed := AppOwner(app.Spec)
+		if !managed || !appfile.ownsPrunable(owner) || declared[name] {
+			continue
+		}
+
+		pruneList = append(pruneList, app)
+	}
+
+	sort.Slice(pruneList, func(i, j int) bool {
+		return pruneList[i].Spec.Name < pruneList[j].Spec.Name
+	})
+
+	return pruneList
+}
+
+func (appfile *Appfile) Diff(ctx context.Context) ([]*AppDiff, error) {
+	remoteApps, err := appfile.readAppsFromRemote(ctx)
 	if err != nil {
 		return []*AppDiff{}, err
 	}
@@ -156,8 +751,8 @@ func (appfile *Appfile) Diff() ([]*AppDiff, error) {
 	return appDiffs, nil
 }
 
-func (appfile *Appfile) Status() ([]*AppStatus, error) {
-	remoteApps, err := appfile.readAppsFromRemote()
+func (appfile *Appfile) Status(ctx context.Context) ([]*AppStatus, error) {
+	remoteApps, err := appfile.readAppsFromRemote(ctx)
 	if err != nil {
 		return []*AppStatus{}, err
 	}
@@ -191,23 +786,30 @@ func (appfile *Appfile) Status() ([]*AppStatus, error) {
 
 			appsStatus = append(appsStatus, appStatus)
 		} else {
-			log.Warningf("%s app not found in App Platform", appSpec.Name)
+			appfile.appLogger(appSpec.Name, operationStatus).Warningf("App not found in App Platform")
 		}
 	}
 
 	return appsStatus, nil
 }
 
-func (appfile *Appfile) Lint() ([]AppLint, error) {
+// LintOptions configures the checks of Lint on top of the validation of the specs
+type LintOptions struct {
+	// MaxCost fails the apps costing more than the given USD per month, when set
+	MaxCost *float32
+	// MaxCostIncrease fails the apps whose cost increases by more than the
+	// given USD per month over the app running in DigitalOcean, when set
+	MaxCostIncrease *float32
+}
+
+func (appfile *Appfile) Lint(ctx context.Context, opts LintOptions) ([]AppLint, error) {
 	lints := []AppLint{}
 
-	remoteApps, err := appfile.readAppsFromRemote()
+	remoteApps, err := appfile.readAppsFromRemote(ctx)
 	if err != nil {
 		return []AppLint{}, err
 	}
 
-	svc := do.NewAppService(appfile.token)
-
 	for _, appSpec := range appfile.AppSpecs {
 		lint := AppLint{
 			Name:     appSpec.Name,
@@ -215,41 +817,99 @@ func (appfile *Appfile) Lint() ([]AppLint, error) {
 			Errors:   appSpec.Validate(),
 		}
 
+		if len(lint.Errors) == 0 {
+			lint.Errors = appfile.proposeApp(ctx, appSpec.AppSpec, remoteApps[appSpec.Name], opts, &lint)
+		}
+
 		lints = append(lints, lint)
+	}
 
-		if len(lint.Errors) != 0 {
-			continue
-		}
+	return lints, nil
+}
 
-		remoteApp, ok := remoteApps[appSpec.Name]
-		localApp := &godo.App{
-			Spec: appSpec.AppSpec,
+// proposeApp proposes the spec to DigitalOcean, recording the response in the
+// lint, and returns the errors found in the response
+func (appfile *Appfile) proposeApp(ctx context.Context, spec *godo.AppSpec, remoteApp *godo.App, opts LintOptions, lint *AppLint) []error {
+	appSvc := appfile.accountFor(spec.Name).appSvc
+	localApp := &godo.App{
+		Spec: spec,
+	}
+
+	if remoteApp != nil {
+		localApp.ID = remoteApp.ID
+	}
+
+	proposal, err := appSvc.Propose(ctx, localApp)
+	if err != nil {
+		return []error{err}
+	}
+	lint.Proposal = proposal
+
+	if remoteApp != nil {
+		current, err := appSvc.Propose(ctx, remoteApp)
+		if err != nil {
+			appfile.appLogger(spec.Name, operationLint).Warningf("Failed to get the cost of the app running in DigitalOcean: %s", err)
+		} else {
+			lint.CurrentCost = &current.AppCost
 		}
+	}
 
-		if ok {
-			localApp.ID = remoteApp.ID
+	errs := []error{}
+	if !proposal.AppNameAvailable {
+		errs = append(errs, apperrors.New(apperrors.KindValidation, "App name %s is taken by another app or team. Suggested name: %s", spec.Name, proposal.AppNameSuggestion))
+	}
+
+	if opts.MaxCost != nil && proposal.AppCost > *opts.MaxCost {
+		errs = append(errs, apperrors.New(apperrors.KindValidation, "App costs $%.2f/mo, above the maximum of $%.2f/mo", proposal.AppCost, *opts.MaxCost))
+	}
+
+	// New apps increase the cost by their full cost. Existing apps whose
+	// current cost is unknown are not checked
+	if opts.MaxCostIncrease != nil && (remoteApp == nil || lint.CurrentCost != nil) {
+		increase := proposal.AppCost
+		if lint.CurrentCost != nil {
+			increase -= *lint.CurrentCost
 		}
 
-		lint.Errors = append(lint.Errors, svc.Propose(localApp))
+		if increase > *opts.MaxCostIncrease {
+			errs = append(errs, apperrors.New(apperrors.KindValidation, "App costs $%.2f/mo more than the app running in DigitalOcean, above the maximum increase of $%.2f/mo", increase, *opts.MaxCostIncrease))
+		}
 	}
 
-	return lints, nil
+	return errs
 }
 
-func (appfile *Appfile) readAppsFromRemote() (map[string]*godo.App, error) {
+// readAppsFromRemote returns the apps of every account, by name. Declared apps
+// are only read from their own account
+func (appfile *Appfile) readAppsFromRemote(ctx context.Context) (map[string]*godo.App, error) {
 	log.Debugln("Get apps running in DigitalOcean")
-	svc := do.NewAppService(appfile.token)
-
-	remoteApps, err := svc.ListApps()
-	if err != nil {
-		return map[string]*godo.App{}, errors.Wrap(err, "Failed to get apps data from DigitalOcean")
-	}
 
 	mapping := map[string]*godo.App{}
 
-	for _, app := range remoteApps {
-		mapping[app.Spec.Name] = app
+	for _, acc := range appfile.accounts {
+		remoteApps, err := acc.appSvc.ListApps(ctx)
+		if err != nil {
+			return map[string]*godo.App{}, errors.Wrap(err, "Failed to get apps data from DigitalOcean")
+		}
+
+		for _, app := range remoteApps {
+			if declaredAcc, ok := appfile.appAccounts[app.Spec.Name]; ok && declaredAcc != acc {
+				continue
+			}
+
+			mapping[app.Spec.Name] = app
+			appfile.remoteAccounts[app.Spec.Name] = acc
+		}
 	}
 
 	return mapping, nil
 }
+
+func appNames(apps []*godo.App) []string {
+	names := []string{}
+	for _, app := range apps {
+		names = append(names, app.Spec.Name)
+	}
+
+	return names
+}
@@ -1,23 +1,144 @@
 package apps
 
 import (
+	"bytes"
 	"fmt"
+	"io/ioutil"
 	"path/filepath"
+	"sort"
+	"strings"
 
+	goyaml "github.com/goccy/go-yaml"
 	"github.com/pkg/errors"
+	"github.com/renehernandez/appfile/internal/auth"
 	"github.com/renehernandez/appfile/internal/env"
+	apperrors "github.com/renehernandez/appfile/internal/errors"
 	"github.com/renehernandez/appfile/internal/log"
+	"github.com/renehernandez/appfile/internal/maputil"
+	"github.com/renehernandez/appfile/internal/schema"
 	"github.com/renehernandez/appfile/internal/tmpl"
 	"github.com/renehernandez/appfile/internal/yaml"
 )
 
 type AppfileSpec struct {
-	AppSpecs     []string            `yaml:"specs"`
-	Environments map[string][]string `yaml:"environments"`
+	AppSpecs      []*AppSpecEntry             `yaml:"specs"`
+	Environments  map[string]*EnvironmentSpec `yaml:"environments"`
+	Hooks         []*Hook                     `yaml:"hooks"`
+	Appfiles      []*AppfileEntry             `yaml:"appfiles"`
+	ValuesSchema  string                      `yaml:"valuesSchema"`
+	MergeStrategy *MergeStrategySpec          `yaml:"mergeStrategy"`
+	ValuesFromEnv *ValuesFromEnvSpec          `yaml:"valuesFromEnv"`
+	TokenCommand  *auth.Command               `yaml:"tokenCommand"`
+	// Project identifies the appfile in the ownership markers of its apps.
+	// Defaults to the name of the directory holding the appfile
+	Project string `yaml:"project"`
 
 	path string
 }
 
+// ReadAppfileSpec renders and parses the appfile spec at file
+func ReadAppfileSpec(file string) (*AppfileSpec, error) {
+	templatedYaml, err := tmpl.RenderFromFile(file)
+	if err != nil {
+		return &AppfileSpec{}, err
+	}
+
+	return ParseAppfileSpec(templatedYaml, file)
+}
+
+// schemaError classifies the violations of a schema as validation errors, and
+// the failures to read the document or the schema as config errors
+func schemaError(err error) error {
+	var validationErr *schema.ValidationError
+	if errors.As(err, &validationErr) {
+		return apperrors.Wrap(apperrors.KindValidation, err)
+	}
+
+	return apperrors.Wrap(apperrors.KindConfig, err)
+}
+
+// ParseAppfileSpec validates the rendered appfile spec read from file against
+// the appfile schema and parses it
+func ParseAppfileSpec(templatedYaml *bytes.Buffer, file string) (*AppfileSpec, error) {
+	if err := schema.Validate(schema.Appfile, templatedYaml.Bytes()); err != nil {
+		return &AppfileSpec{}, errors.Wrapf(schemaError(err), "Invalid appfile spec in file %s", file)
+	}
+
+	var spec AppfileSpec
+	if err := yaml.ParseAppfileSpec(templatedYaml, &spec); err != nil {
+		return &AppfileSpec{}, errors.Wrapf(err, "Could not parse appfile spec from file %s", file)
+	}
+
+	if err := spec.SetPath(file); err != nil {
+		return &AppfileSpec{}, errors.Wrapf(err, "Could not generate absolute path for file %s", file)
+	}
+
+	return &spec, nil
+}
+
+// AppfileEntry references a nested appfile spec declared in the appfile spec.
+// It can be written as the plain path to the file or as a map
+type AppfileEntry struct {
+	Path string `yaml:"path"`
+	// Environments maps the environment names of this appfile to the ones of the nested appfile
+	Environments map[string]string `yaml:"environments"`
+	// Values are merged on top of the values of the nested appfile environment
+	Values []*ValuesEntry `yaml:"values"`
+}
+
+func (entry *AppfileEntry) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var path string
+	if err := unmarshal(&path); err == nil {
+		entry.Path = path
+		return nil
+	}
+
+	type rawAppfileEntry AppfileEntry
+	var raw rawAppfileEntry
+	if err := unmarshal(&raw); err != nil {
+		return err
+	}
+
+	*entry = AppfileEntry(raw)
+	return nil
+}
+
+func (entry *AppfileEntry) environmentFor(envName string) string {
+	if mapped, ok := entry.Environments[envName]; ok {
+		return mapped
+	}
+
+	return envName
+}
+
+// AppSpecEntry references an app spec file declared in the appfile spec.
+// It can be written as the plain path to the file or as a map
+type AppSpecEntry struct {
+	Path         string   `yaml:"path"`
+	Hooks        []*Hook  `yaml:"hooks"`
+	Environments []string `yaml:"environments"`
+	Condition    string   `yaml:"condition"`
+	// Token replaces the access token of the environment for the app specs
+	Token *auth.Source `yaml:"token"`
+}
+
+func (entry *AppSpecEntry) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var path string
+	if err := unmarshal(&path); err == nil {
+		entry.Path = path
+		return nil
+	}
+
+	type rawAppSpecEntry AppSpecEntry
+	var raw rawAppSpecEntry
+	if err := unmarshal(&raw); err != nil {
+		return err
+	}
+
+	*entry = AppSpecEntry(raw)
+	return nil
+}
+
 func (spec *AppfileSpec) Path() string {
 	return spec.path
 }
@@ -25,11 +146,107 @@ func (spec *AppfileSpec) Path() string {
 func (spec *AppfileSpec) SetPath(path string) error {
 	var err error
 	spec.path, err = filepath.Abs(path)
-	return err
+	if err != nil {
+		return err
+	}
+
+	dir := filepath.Dir(spec.path)
+
+	if spec.TokenCommand != nil {
+		spec.TokenCommand.SetDir(dir)
+	}
+
+	for _, envSpec := range spec.Environments {
+		if envSpec != nil && envSpec.Token != nil {
+			envSpec.Token.SetDir(dir)
+		}
+	}
+
+	for _, entry := range spec.AppSpecs {
+		if entry.Token != nil {
+			entry.Token.SetDir(dir)
+		}
+	}
+
+	return nil
 }
 
 func (spec *AppfileSpec) IsValid() bool {
-	return len(spec.AppSpecs) > 0
+	return len(spec.AppSpecs) > 0 || len(spec.Appfiles) > 0
+}
+
+// isEnabled reports whether the entry applies to the environment in state.
+// The entry is enabled when the environment is one of its environments, if any,
+// and its condition, if any, evaluates to true
+func (entry *AppSpecEntry) isEnabled(state *StateData) (bool, error) {
+	if len(entry.Environments) > 0 {
+		found := false
+		for _, name := range entry.Environments {
+			if name == state.Environment.Name {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return false, nil
+		}
+	}
+
+	if entry.Condition == "" {
+		return true, nil
+	}
+
+	return evaluateCondition(entry.Condition, state)
+}
+
+// evaluateCondition looks up the boolean value referenced by condition,
+// a path starting with values, e.g values.postgres.enabled
+func evaluateCondition(condition string, state *StateData) (bool, error) {
+	keys := maputil.ParseKey(condition)
+	if len(keys) < 2 || (keys[0] != "values" && keys[0] != "Values") {
+		return false, fmt.Errorf("Condition %s must reference a value under values, e.g values.app.enabled", condition)
+	}
+
+	var current interface{} = state.Values
+	for _, key := range keys[1:] {
+		values, ok := current.(map[string]interface{})
+		if !ok {
+			return false, fmt.Errorf("Condition %s not found in environment %s values", condition, state.Environment.Name)
+		}
+
+		current, ok = values[key]
+		if !ok {
+			return false, fmt.Errorf("Condition %s not found in environment %s values", condition, state.Environment.Name)
+		}
+	}
+
+	enabled, ok := current.(bool)
+	if !ok {
+		return false, fmt.Errorf("Condition %s must be a boolean value, got %v", condition, current)
+	}
+
+	return enabled, nil
+}
+
+func (spec *AppfileSpec) validateHooks() error {
+	hooks := append([]*Hook{}, spec.Hooks...)
+	for _, entry := range spec.AppSpecs {
+		hooks = append(hooks, entry.Hooks...)
+	}
+
+	for _, hook := range hooks {
+		if err := hook.validate(); err != nil {
+			return errors.Wrapf(err, "Invalid hook in appfile spec at %s", spec.Path())
+		}
+	}
+
+	return nil
+}
+
+// globalHooks returns the hooks declared at the top level of the appfile spec
+func (spec *AppfileSpec) globalHooks() []*Hook {
+	return withDir(spec.Hooks, filepath.Dir(spec.Path()))
 }
 
 func (spec *AppfileSpec) hasEnvironment(name string) bool {
@@ -37,64 +254,253 @@ func (spec *AppfileSpec) hasEnvironment(name string) bool {
 	return ok
 }
 
+// environmentNames returns the sorted names of the declared environments
+func (spec *AppfileSpec) environmentNames() []string {
+	names := []string{}
+	for name := range spec.Environments {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
 func (spec *AppfileSpec) ReadEnvironment(name string) (*env.Environment, error) {
+	fullEnv, err := spec.readDeclaredEnvironment(name)
+	if err != nil {
+		return &env.Environment{}, err
+	}
+
+	return spec.mergeValuesFromEnv(fullEnv)
+}
+
+// readDeclaredEnvironment reads the values of the environment declared in the appfile spec
+func (spec *AppfileSpec) readDeclaredEnvironment(name string) (*env.Environment, error) {
 	if !spec.hasEnvironment(name) {
-		if name != "default" {
-			return &env.Environment{}, fmt.Errorf("Environment %s not found in appfile spec at %s", name, spec.Path())
+		// The default environment and the environments of appfiles only
+		// composing nested appfiles don't need to be declared
+		if name != "default" && (len(spec.Environments) > 0 || len(spec.AppSpecs) > 0) {
+			declared := "none"
+			if len(spec.Environments) > 0 {
+				declared = strings.Join(spec.environmentNames(), ", ")
+			}
+
+			return &env.Environment{}, apperrors.New(apperrors.KindConfig, "Environment %s not found in appfile spec at %s. Declared environments: %s", name, spec.Path(), declared)
 		}
 
-		log.Debugf("Using default environment without any defined values")
-		return &env.Environment{}, nil
+		log.Debugf("Using %s environment without any defined values", name)
+		return &env.Environment{Name: name}, nil
 	}
 
-	fullEnv := &env.Environment{
-		Name: name,
+	return spec.resolveEnvironment(name, []string{})
+}
+
+// project returns the project of the appfile, defaulting to the name of the
+// directory holding it
+func (spec *AppfileSpec) project() string {
+	if spec.Project != "" {
+		return spec.Project
 	}
 
-	for _, envPath := range spec.Environments[name] {
-		file := filepath.Join(filepath.Dir(spec.Path()), envPath)
-		log.Debugf("Reading environment values from %s", file)
-		templatedYaml, err := tmpl.RenderFromFile(file)
+	return defaultProject(spec.Path())
+}
+
+// load reads the environment and the app specs of the appfile spec, followed by
+// the app specs of its nested appfiles. The extra values, relative to valuesDir,
+// are merged on top of the environment values. The token source is used by the
+// app specs of environments without their own token source. Every app spec is
+// owned by the project and environment of the appfile declaring it, and the
+// owners of the appfile and of its nested appfiles are returned
+func (spec *AppfileSpec) load(envName string, extraValues []*ValuesEntry, valuesDir string, token *auth.Source, chain []string) (*StateData, []*AppSpec, []Owner, error) {
+	for _, path := range chain {
+		if path == spec.Path() {
+			return &StateData{}, []*AppSpec{}, []Owner{}, fmt.Errorf("Appfile cycle detected: %s", strings.Join(append(chain, spec.Path()), " -> "))
+		}
+	}
+	chain = append(chain, spec.Path())
+
+	if err := spec.validateHooks(); err != nil {
+		return &StateData{}, []*AppSpec{}, []Owner{}, err
+	}
+
+	fullEnv, err := spec.readDeclaredEnvironment(envName)
+	if err != nil {
+		return &StateData{}, []*AppSpec{}, []Owner{}, err
+	}
+
+	mergeOpts, err := spec.mergeOptions()
+	if err != nil {
+		return &StateData{}, []*AppSpec{}, []Owner{}, err
+	}
+
+	for _, entry := range extraValues {
+		envPart, err := entry.read(valuesDir, envName)
 		if err != nil {
-			return &env.Environment{}, err
+			return &StateData{}, []*AppSpec{}, []Owner{}, err
 		}
 
-		currentEnvPart, err := yaml.ParseEnvironment(templatedYaml)
+		fullEnv, err = fullEnv.MergeWithOptions(envPart, mergeOpts)
+		if err != nil {
+			return &StateData{}, []*AppSpec{}, []Owner{}, errors.Wrapf(err, "Could not merge extra values from %s in env %s", entry, envName)
+		}
+	}
+
+	fullEnv, err = spec.mergeValuesFromEnv(fullEnv)
+	if err != nil {
+		return &StateData{}, []*AppSpec{}, []Owner{}, err
+	}
+
+	if err = spec.validateValues(fullEnv); err != nil {
+		return &StateData{}, []*AppSpec{}, []Owner{}, err
+	}
+
+	state := &StateData{
+		Environment: EnvMetadata{
+			Name: fullEnv.Name,
+		},
+		Values: fullEnv.Values,
+	}
+
+	envToken, err := spec.environmentToken(envName, []string{})
+	if err != nil {
+		return &StateData{}, []*AppSpec{}, []Owner{}, err
+	}
+	if envToken != nil {
+		token = envToken
+	}
+
+	appSpecs, err := spec.loadAppSpecs(state, token)
+	if err != nil {
+		return &StateData{}, []*AppSpec{}, []Owner{}, err
+	}
+
+	owner := Owner{
+		Project:     spec.project(),
+		Environment: state.Environment.Name,
+	}
+	for _, appSpec := range appSpecs {
+		appSpec.SetOwner(owner)
+	}
+	owners := []Owner{owner}
+
+	for _, entry := range spec.Appfiles {
+		file := filepath.Join(filepath.Dir(spec.Path()), entry.Path)
+		log.Debugf("Reading nested appfile spec from %s", file)
+
+		nested, err := ReadAppfileSpec(file)
 		if err != nil {
-			return &env.Environment{}, errors.Wrapf(err, "Could not parse resulting yaml from file %s in env %s", file, name)
+			return &StateData{}, []*AppSpec{}, []Owner{}, err
 		}
 
-		fullEnv, err = fullEnv.Merge(currentEnvPart)
+		_, nestedAppSpecs, nestedOwners, err := nested.load(entry.environmentFor(envName), entry.Values, filepath.Dir(spec.Path()), token, chain)
 		if err != nil {
-			return &env.Environment{}, errors.Wrapf(err, "Could not merge values from file %s in env %s", file, name)
+			return &StateData{}, []*AppSpec{}, []Owner{}, errors.Wrapf(err, "Could not load nested appfile %s", file)
 		}
+
+		appSpecs = append(appSpecs, nestedAppSpecs...)
+		owners = append(owners, nestedOwners...)
 	}
 
-	return fullEnv, nil
+	return state, appSpecs, owners, nil
 }
 
-func (spec *AppfileSpec) loadAppSpecs(state *StateData) ([]*AppSpec, error) {
+// validateValues validates the merged values of the environment against the
+// values schema declared in the appfile spec, if any
+func (spec *AppfileSpec) validateValues(fullEnv *env.Environment) error {
+	if spec.ValuesSchema == "" {
+		return nil
+	}
+
+	file := spec.ValuesSchema
+	if !filepath.IsAbs(file) {
+		file = filepath.Join(filepath.Dir(spec.Path()), file)
+	}
+
+	log.Debugf("Validating values of env %s against schema %s", fullEnv.Name, file)
+	valuesSchema, err := ioutil.ReadFile(file)
+	if err != nil {
+		return errors.Wrapf(err, "Could not read values schema %s", file)
+	}
+
+	values := fullEnv.Values
+	if values == nil {
+		values = map[string]interface{}{}
+	}
+
+	valuesYaml, err := goyaml.Marshal(values)
+	if err != nil {
+		return errors.Wrapf(err, "Could not convert values of env %s to yaml", fullEnv.Name)
+	}
+
+	if err = schema.ValidateWithSchema("values", valuesSchema, valuesYaml); err != nil {
+		return errors.Wrapf(schemaError(err), "Values of env %s are not valid according to %s", fullEnv.Name, file)
+	}
+
+	return nil
+}
+
+func (spec *AppfileSpec) loadAppSpecs(state *StateData, token *auth.Source) ([]*AppSpec, error) {
 	appSpecs := []*AppSpec{}
+	baseDir := filepath.Dir(spec.Path())
 
-	for _, appSpecPath := range spec.AppSpecs {
-		file := filepath.Join(filepath.Dir(spec.Path()), appSpecPath)
-		log.Debugf("Reading app spec from %s", file)
-		templatedYaml, err := tmpl.RenderFromFile(file, state)
+	for _, entry := range spec.AppSpecs {
+		enabled, err := entry.isEnabled(state)
 		if err != nil {
-			return []*AppSpec{}, err
+			return []*AppSpec{}, errors.Wrapf(err, "Could not evaluate app spec entry %s", entry.Path)
+		}
+
+		if !enabled {
+			log.Debugf("Skipping app spec entry %s in environment %s", entry.Path, state.Environment.Name)
+			continue
 		}
 
-		appSpec := NewAppSpec()
-		err = yaml.ParseAppSpec(templatedYaml, appSpec)
+		files, err := expandSpecPath(baseDir, entry.Path)
 		if err != nil {
-			return []*AppSpec{}, errors.Wrapf(err, "Could not parse resulting yaml for app spec from file %s", file)
+			return []*AppSpec{}, err
+		}
+
+		entryToken := token
+		if entry.Token != nil {
+			if err := entry.Token.Validate(); err != nil {
+				return []*AppSpec{}, errors.Wrapf(err, "Invalid token of app spec entry %s", entry.Path)
+			}
+			entryToken = entry.Token
 		}
 
-		appSpec.FileName = filepath.Base(file)
-		appSpec.SetDefaultValues()
+		for _, file := range files {
+			log.Debugf("Reading app spec from %s", file)
+			templatedYaml, err := tmpl.RenderFromFile(file, state)
+			if err != nil {
+				return []*AppSpec{}, err
+			}
+
+			appSpec, err := ParseAppSpec(templatedYaml, file)
+			if err != nil {
+				return []*AppSpec{}, err
+			}
 
-		appSpecs = append(appSpecs, appSpec)
+			appSpec.hooks = append(spec.globalHooks(), withDir(entry.Hooks, baseDir)...)
+			appSpec.tokenSource = entryToken
+			appSpec.SetDefaultValues()
+
+			appSpecs = append(appSpecs, appSpec)
+		}
 	}
 
 	return appSpecs, nil
 }
+
+// checkDuplicateNames verifies that no two app spec files render the same app name
+func checkDuplicateNames(appSpecs []*AppSpec) error {
+	files := map[string]string{}
+
+	for _, appSpec := range appSpecs {
+		if file, ok := files[appSpec.Name]; ok {
+			return fmt.Errorf("App name %s is declared by both %s and %s", appSpec.Name, file, appSpec.filePath)
+		}
+
+		files[appSpec.Name] = appSpec.filePath
+	}
+
+	return nil
+}
@@ -0,0 +1,330 @@
+package apps
+
+import (
+	"bytes"
+	"os"
+	"testing"
+
+	apperrors "github.com/renehernandez/appfile/internal/errors"
+	"github.com/renehernandez/appfile/internal/yaml"
+	"github.com/stretchr/testify/suite"
+)
+
+type AppfileSpecSuite struct {
+	suite.Suite
+}
+
+func (suite *AppfileSpecSuite) TestParseSpecEntries() {
+	content := `specs:
+- ./app.yaml
+- path: ./worker.yaml
+  hooks:
+  - events: ["presync"]
+    command: echo
+    args: ["hello"]
+`
+	var spec AppfileSpec
+	err := yaml.ParseAppfileSpec(bytes.NewBufferString(content), &spec)
+
+	suite.NoError(err)
+	suite.Len(spec.AppSpecs, 2)
+	suite.Equal("./app.yaml", spec.AppSpecs[0].Path)
+	suite.Empty(spec.AppSpecs[0].Hooks)
+	suite.Equal("./worker.yaml", spec.AppSpecs[1].Path)
+	suite.Len(spec.AppSpecs[1].Hooks, 1)
+	suite.Equal([]string{"presync"}, spec.AppSpecs[1].Hooks[0].Events)
+	suite.Equal([]string{"hello"}, spec.AppSpecs[1].Hooks[0].Args)
+}
+
+func (suite *AppfileSpecSuite) TestReadEnvironmentWithExtends() {
+	spec := parseAppfileSpec(suite, `environments:
+  base:
+    values:
+    - name: base
+      rails:
+        instance_count: 1
+        instance_slug: basic-xxs
+  staging:
+    extends: [base]
+    values:
+    - rails:
+        instance_count: 2
+  production:
+    extends: [staging]
+    values:
+    - name: production
+specs:
+- ./app.yaml
+`)
+
+	env, err := spec.ReadEnvironment("production")
+
+	suite.NoError(err)
+	suite.Equal("production", env.Name)
+	suite.Equal(map[string]interface{}{
+		"name": "production",
+		"rails": map[string]interface{}{
+			"instance_count": uint64(2),
+			"instance_slug":  "basic-xxs",
+		},
+	}, env.Values)
+}
+
+func (suite *AppfileSpecSuite) TestReadEnvironmentLegacyList() {
+	spec := parseAppfileSpec(suite, `environments:
+  review:
+  - ../../testdata/templated_envs/default_env.yaml
+specs:
+- ./app.yaml
+`)
+
+	env, err := spec.ReadEnvironment("review")
+
+	suite.NoError(err)
+	suite.Equal("default_name", env.Values["spec_name"])
+}
+
+func (suite *AppfileSpecSuite) TestReadEnvironmentCycle() {
+	spec := parseAppfileSpec(suite, `environments:
+  a:
+    extends: [b]
+  b:
+    extends: [c]
+  c:
+    extends: [a]
+specs:
+- ./app.yaml
+`)
+
+	_, err := spec.ReadEnvironment("a")
+
+	suite.EqualError(err, "Environment cycle detected: a -> b -> c -> a")
+}
+
+func (suite *AppfileSpecSuite) TestReadEnvironmentMissingBase() {
+	spec := parseAppfileSpec(suite, `environments:
+  review:
+    extends: [base]
+specs:
+- ./app.yaml
+`)
+
+	_, err := spec.ReadEnvironment("review")
+
+	suite.Error(err)
+	suite.Contains(err.Error(), "Environment base extended by review not found")
+}
+
+func (suite *AppfileSpecSuite) TestReadEnvironmentUnknown() {
+	spec := parseAppfileSpec(suite, `environments:
+  review: {}
+  production: {}
+specs:
+- ./app.yaml
+`)
+
+	_, err := spec.ReadEnvironment("staging")
+
+	suite.Require().Error(err)
+	suite.Equal(apperrors.KindConfig, apperrors.KindOf(err))
+	suite.Contains(err.Error(), "Environment staging not found in appfile spec")
+	suite.Contains(err.Error(), "Declared environments: production, review")
+
+	defaultEnv, err := spec.ReadEnvironment("default")
+	suite.Require().NoError(err)
+	suite.Equal("default", defaultEnv.Name)
+}
+
+func (suite *AppfileSpecSuite) TestReadEnvironmentWithMergeStrategy() {
+	spec := parseAppfileSpec(suite, `mergeStrategy:
+  default: append
+  keys:
+    services: merge-by-key:name
+environments:
+  base:
+    values:
+    - domains: [base.example.com]
+      services:
+      - name: web
+        instance_count: 1
+  production:
+    extends: [base]
+    values:
+    - domains: [example.com]
+      services:
+      - name: web
+        instance_count: 3
+      - name: worker
+specs:
+- ./app.yaml
+`)
+
+	env, err := spec.ReadEnvironment("production")
+
+	suite.NoError(err)
+	suite.Equal([]interface{}{"base.example.com", "example.com"}, env.Values["domains"])
+	suite.Equal([]interface{}{
+		map[string]interface{}{"name": "web", "instance_count": uint64(3)},
+		map[string]interface{}{"name": "worker"},
+	}, env.Values["services"])
+}
+
+func (suite *AppfileSpecSuite) TestReadEnvironmentInvalidMergeStrategy() {
+	spec := parseAppfileSpec(suite, `mergeStrategy: zip
+environments:
+  review:
+  - name: review
+specs:
+- ./app.yaml
+`)
+
+	_, err := spec.ReadEnvironment("review")
+
+	suite.Error(err)
+	suite.Contains(err.Error(), "Invalid mergeStrategy in appfile spec")
+}
+
+func (suite *AppfileSpecSuite) TestReadEnvironmentWithValuesFromEnv() {
+	os.Setenv("APPFILE_VALUES_rails__instance_count", "3")
+	os.Setenv("APPFILE_VALUES_rails__debug", "true")
+	defer os.Unsetenv("APPFILE_VALUES_rails__instance_count")
+	defer os.Unsetenv("APPFILE_VALUES_rails__debug")
+
+	spec := parseAppfileSpec(suite, `environments:
+  review:
+  - rails:
+      instance_count: 1
+      instance_slug: basic-xxs
+specs:
+- ./app.yaml
+`)
+
+	env, err := spec.ReadEnvironment("review")
+
+	suite.NoError(err)
+	suite.Equal(map[string]interface{}{
+		"rails": map[string]interface{}{
+			"instance_count": int64(3),
+			"instance_slug":  "basic-xxs",
+			"debug":          true,
+		},
+	}, env.Values)
+}
+
+func (suite *AppfileSpecSuite) TestReadEnvironmentWithCustomValuesFromEnv() {
+	os.Setenv("CI_VALUES_rails_instance_count", "2")
+	defer os.Unsetenv("CI_VALUES_rails_instance_count")
+
+	spec := parseAppfileSpec(suite, `valuesFromEnv:
+  prefix: CI_VALUES_
+  separator: _
+specs:
+- ./app.yaml
+`)
+
+	env, err := spec.ReadEnvironment("default")
+
+	suite.NoError(err)
+	suite.Equal(map[string]interface{}{
+		"rails": map[string]interface{}{
+			"instance": map[string]interface{}{
+				"count": int64(2),
+			},
+		},
+	}, env.Values)
+}
+
+func (suite *AppfileSpecSuite) TestParseTokenCommand() {
+	spec, err := ParseAppfileSpec(bytes.NewBufferString(`tokenCommand:
+  command: echo
+  args: [secret-token]
+specs:
+- ./app.yaml
+`), "appfile.yaml")
+	suite.Require().NoError(err)
+
+	token, err := spec.TokenCommand.Token()
+
+	suite.NoError(err)
+	suite.Equal("secret-token", token)
+
+	spec, err = ParseAppfileSpec(bytes.NewBufferString(`tokenCommand: ./token.sh
+specs:
+- ./app.yaml
+`), "appfile.yaml")
+	suite.Require().NoError(err)
+	suite.Equal("./token.sh", spec.TokenCommand.Command)
+}
+
+func (suite *AppfileSpecSuite) TestSpecEntryEnabledByEnvironment() {
+	entry := &AppSpecEntry{
+		Path:         "./postgres.yaml",
+		Environments: []string{"review", "staging"},
+	}
+
+	enabled, err := entry.isEnabled(stateFor("review", nil))
+	suite.NoError(err)
+	suite.True(enabled)
+
+	enabled, err = entry.isEnabled(stateFor("production", nil))
+	suite.NoError(err)
+	suite.False(enabled)
+}
+
+func (suite *AppfileSpecSuite) TestSpecEntryEnabledByCondition() {
+	entry := &AppSpecEntry{
+		Path:      "./postgres.yaml",
+		Condition: "values.postgres.enabled",
+	}
+	values := map[string]interface{}{
+		"postgres": map[string]interface{}{
+			"enabled": false,
+		},
+	}
+
+	enabled, err := entry.isEnabled(stateFor("review", values))
+	suite.NoError(err)
+	suite.False(enabled)
+
+	values["postgres"].(map[string]interface{})["enabled"] = true
+	enabled, err = entry.isEnabled(stateFor("review", values))
+	suite.NoError(err)
+	suite.True(enabled)
+}
+
+func (suite *AppfileSpecSuite) TestSpecEntryInvalidCondition() {
+	values := map[string]interface{}{
+		"postgres": map[string]interface{}{
+			"enabled": "yes",
+		},
+	}
+
+	_, err := (&AppSpecEntry{Condition: "postgres.enabled"}).isEnabled(stateFor("review", values))
+	suite.EqualError(err, "Condition postgres.enabled must reference a value under values, e.g values.app.enabled")
+
+	_, err = (&AppSpecEntry{Condition: "values.redis.enabled"}).isEnabled(stateFor("review", values))
+	suite.EqualError(err, "Condition values.redis.enabled not found in environment review values")
+
+	_, err = (&AppSpecEntry{Condition: "values.postgres.enabled"}).isEnabled(stateFor("review", values))
+	suite.EqualError(err, "Condition values.postgres.enabled must be a boolean value, got yes")
+}
+
+func stateFor(envName string, values map[string]interface{}) *StateData {
+	return &StateData{
+		Environment: EnvMetadata{Name: envName},
+		Values:      values,
+	}
+}
+
+func parseAppfileSpec(suite *AppfileSpecSuite, content string) *AppfileSpec {
+	var spec AppfileSpec
+	err := yaml.ParseAppfileSpec(bytes.NewBufferString(content), &spec)
+	suite.Require().NoError(err)
+	suite.Require().NoError(spec.SetPath("appfile.yaml"))
+
+	return &spec
+}
+
+func TestAppfileSpecSuite(t *testing.T) {
+	suite.Run(t, &AppfileSpecSuite{})
+}
@@ -0,0 +1,432 @@
+package apps
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/digitalocean/godo"
+	"github.com/renehernandez/appfile/internal/auth"
+	"github.com/renehernandez/appfile/internal/do"
+	"github.com/renehernandez/appfile/internal/do/fake"
+	apperrors "github.com/renehernandez/appfile/internal/errors"
+	"github.com/renehernandez/appfile/internal/interrupt"
+	"github.com/stretchr/testify/suite"
+)
+
+type AppfileSuite struct {
+	suite.Suite
+}
+
+func (suite *AppfileSuite) TestNewAppfileFromNestedSpecs() {
+	spec, err := ReadAppfileSpec("../../testdata/nested/appfile.yaml")
+	suite.Require().NoError(err)
+
+	appfile, err := NewAppfileFromSpec(spec, "review", fake.NewBackend(), auth.NewStaticResolver("token"))
+	suite.Require().NoError(err)
+
+	suite.Equal("review", appfile.State.Environment.Name)
+	suite.Len(appfile.AppSpecs, 2)
+	suite.Equal("team-a-review", appfile.AppSpecs[0].Name)
+	suite.Equal("team-b-staging-platform", appfile.AppSpecs[1].Name)
+
+	owners := []Owner{}
+	for _, appSpec := range appfile.AppSpecs {
+		owner, managed := AppOwner(appSpec.AppSpec)
+		suite.True(managed)
+		owners = append(owners, owner)
+	}
+	suite.Equal([]Owner{
+		{Project: "team-a", Environment: "review"},
+		{Project: "team-b", Environment: "staging"},
+	}, owners)
+}
+
+func (suite *AppfileSuite) TestNestedSpecsMissingEnvironment() {
+	spec, err := ReadAppfileSpec("../../testdata/nested/appfile.yaml")
+	suite.Require().NoError(err)
+
+	_, err = NewAppfileFromSpec(spec, "production", fake.NewBackend(), auth.NewStaticResolver("token"))
+
+	suite.Error(err)
+	suite.Contains(err.Error(), "Environment production not found")
+}
+
+func (suite *AppfileSuite) TestValuesSchemaListsEveryViolation() {
+	spec, err := ReadAppfileSpec("../../testdata/values_schema/appfile.yaml")
+	suite.Require().NoError(err)
+
+	_, err = NewAppfileFromSpec(spec, "production", fake.NewBackend(), auth.NewStaticResolver("token"))
+	suite.Require().Error(err)
+	suite.Contains(err.Error(), "rails.instance_count: Invalid type. Expected: integer, given: string")
+	suite.Equal(apperrors.KindValidation, apperrors.KindOf(err))
+
+	_, err = NewAppfileFromSpec(spec, "review", fake.NewBackend(), auth.NewStaticResolver("token"))
+	suite.Require().Error(err)
+	suite.Contains(err.Error(), "rails.instance_slug: instance_slug is required")
+}
+
+func (suite *AppfileSuite) TestSyncSkipsUpdateOnFailingPreSyncHook() {
+	backend := fake.NewBackend()
+	appfile := suite.nestedAppfile(backend)
+
+	_, err := appfile.Sync(context.Background(), SyncOptions{})
+	suite.Require().NoError(err)
+
+	appfile.AppSpecs[0].Region = "ams"
+	appfile.AppSpecs[0].hooks = []*Hook{{Name: "check", Command: "false", Events: []string{HookEventPreSync}}}
+	summary, err := appfile.Sync(context.Background(), SyncOptions{Force: true})
+
+	suite.Require().Error(err)
+	suite.Equal(apperrors.KindDeployment, apperrors.KindOf(err))
+	suite.Contains(err.Error(), "Failed to sync apps: team-a-review")
+	suite.Equal(ResultFailed, summary.Results[0].Result)
+	suite.Contains(summary.Results[0].Err.Error(), "check")
+	suite.Equal(ResultUpdated, summary.Results[1].Result)
+
+	for _, app := range backend.Apps() {
+		if app.Spec.Name == "team-a-review" {
+			suite.NotEqual("ams", app.Spec.Region)
+		}
+	}
+}
+
+func (suite *AppfileSuite) TestPostSyncHooksRunOnDeployedApps() {
+	backend := fake.NewBackend()
+	appfile := suite.nestedAppfile(backend)
+	appfile.AppSpecs[0].hooks = []*Hook{{
+		Name:    "smoke",
+		Command: "sh",
+		Args:    []string{"-c", `test -n "$APPFILE_APP_URL"`},
+		Events:  []string{HookEventPostSync},
+	}}
+
+	summary, err := appfile.Sync(context.Background(), SyncOptions{})
+
+	suite.Require().NoError(err)
+	suite.Equal(2, summary.Count(ResultCreated))
+}
+
+func (suite *AppfileSuite) TestSyncCreatesAndUpdatesApps() {
+	backend := fake.NewBackend()
+	appfile := suite.nestedAppfile(backend)
+
+	summary, err := appfile.Sync(context.Background(), SyncOptions{})
+	suite.Require().NoError(err)
+	suite.Equal(2, summary.Count(ResultCreated))
+
+	apps := backend.Apps()
+	suite.Len(apps, 2)
+	for _, app := range apps {
+		_, managed := AppOwner(app.Spec)
+		suite.True(managed)
+	}
+	firstDeployment := apps[0].ActiveDeployment.ID
+
+	summary, err = appfile.Sync(context.Background(), SyncOptions{})
+	suite.Require().NoError(err)
+	suite.Equal(2, summary.Count(ResultUnchanged))
+	suite.Equal(firstDeployment, backend.Apps()[0].ActiveDeployment.ID)
+
+	summary, err = appfile.Sync(context.Background(), SyncOptions{Force: true})
+	suite.Require().NoError(err)
+	suite.Equal(2, summary.Count(ResultUpdated))
+
+	apps = backend.Apps()
+	suite.Len(apps, 2)
+	suite.Equal(firstDeployment, apps[0].ActiveDeployment.PreviousDeploymentID)
+}
+
+func (suite *AppfileSuite) TestSyncUpdatesChangedApps() {
+	backend := fake.NewBackend()
+	appfile := suite.nestedAppfile(backend)
+
+	_, err := appfile.Sync(context.Background(), SyncOptions{})
+	suite.Require().NoError(err)
+
+	appfile.AppSpecs[0].Region = "ams"
+	summary, err := appfile.Sync(context.Background(), SyncOptions{})
+
+	suite.Require().NoError(err)
+	suite.Equal(ResultUpdated, summary.Results[0].Result)
+	suite.Equal(ResultUnchanged, summary.Results[1].Result)
+}
+
+func (suite *AppfileSuite) TestSyncSkipsAppsWithServerDefaults() {
+	backend := fake.NewBackend()
+	appfile := suite.nestedAppfile(backend)
+	appfile.AppSpecs[0].Services = []*godo.AppServiceSpec{{Name: "web", Image: &godo.ImageSourceSpec{Repository: "web"}}}
+
+	_, err := appfile.Sync(context.Background(), SyncOptions{})
+	suite.Require().NoError(err)
+
+	service := backend.Apps()[0].Spec.Services[0]
+	suite.Equal(8080, int(service.HTTPPort))
+	suite.Len(service.Routes, 1)
+
+	summary, err := appfile.Sync(context.Background(), SyncOptions{})
+
+	suite.Require().NoError(err)
+	suite.Equal(2, summary.Count(ResultUnchanged))
+}
+
+func (suite *AppfileSuite) TestSyncAsksApproval() {
+	backend := fake.NewBackend()
+	appfile := suite.nestedAppfile(backend)
+
+	asked := []string{}
+	summary, err := appfile.Sync(context.Background(), SyncOptions{
+		Approve: func(diff *AppDiff) (string, error) {
+			asked = append(asked, diff.Name)
+			if diff.Name == "team-a-review" {
+				return ApprovalSkip, nil
+			}
+			return ApprovalApply, nil
+		},
+	})
+
+	suite.Require().NoError(err)
+	suite.Equal([]string{"team-a-review", "team-b-staging-platform"}, asked)
+	suite.Equal(ResultSkipped, summary.Results[0].Result)
+	suite.Equal(ResultCreated, summary.Results[1].Result)
+	suite.False(summary.Interrupted)
+	suite.Len(backend.Apps(), 1)
+}
+
+func (suite *AppfileSuite) TestSyncAbortedByApprover() {
+	backend := fake.NewBackend()
+	appfile := suite.nestedAppfile(backend)
+
+	summary, err := appfile.Sync(context.Background(), SyncOptions{
+		Approve: func(diff *AppDiff) (string, error) {
+			return ApprovalAbort, nil
+		},
+	})
+
+	suite.Require().Error(err)
+	suite.Contains(err.Error(), "Sync aborted before syncing apps: team-a-review, team-b-staging-platform")
+	suite.Equal(apperrors.KindAborted, apperrors.KindOf(err))
+	suite.True(summary.Aborted)
+	suite.False(summary.Interrupted)
+	suite.Equal(2, summary.Count(ResultSkipped))
+	suite.Empty(backend.Apps())
+}
+
+func (suite *AppfileSuite) TestLintReportsProposal() {
+	backend := fake.NewBackend()
+	appfile := suite.nestedAppfile(backend)
+
+	lints, err := appfile.Lint(context.Background(), LintOptions{})
+	suite.Require().NoError(err)
+	suite.Require().Len(lints, 2)
+	for _, lint := range lints {
+		suite.Empty(lint.Errors)
+		suite.Require().NotNil(lint.Proposal)
+		suite.True(lint.Proposal.AppNameAvailable)
+		suite.Nil(lint.CurrentCost)
+	}
+
+	_, err = appfile.Sync(context.Background(), SyncOptions{})
+	suite.Require().NoError(err)
+
+	lints, err = appfile.Lint(context.Background(), LintOptions{})
+	suite.Require().NoError(err)
+	suite.Require().NotNil(lints[0].CurrentCost)
+	suite.Equal(lints[0].Proposal.AppCost, *lints[0].CurrentCost)
+}
+
+func (suite *AppfileSuite) TestLintFailsOnCostThresholds() {
+	backend := fake.NewBackend()
+	appfile := suite.nestedAppfile(backend)
+	appfile.AppSpecs[0].Services = []*godo.AppServiceSpec{
+		{Name: "api", InstanceCount: 2, InstanceSizeSlug: "basic-xxs", Image: &godo.ImageSourceSpec{RegistryType: godo.ImageSourceSpecRegistryType_DOCR, Repository: "api"}},
+	}
+
+	maxCost := float32(0)
+	lints, err := appfile.Lint(context.Background(), LintOptions{MaxCost: &maxCost, MaxCostIncrease: &maxCost})
+	suite.Require().NoError(err)
+
+	suite.Require().Len(lints[0].Errors, 2)
+	suite.Contains(lints[0].Errors[0].Error(), "App costs $10.00/mo, above the maximum of $0.00/mo")
+	suite.Contains(lints[0].Errors[1].Error(), "App costs $10.00/mo more than the app running in DigitalOcean, above the maximum increase of $0.00/mo")
+	suite.Empty(lints[1].Errors)
+	suite.Equal(apperrors.KindValidation, apperrors.KindOf(lints[0].Errors[0]))
+
+	_, err = appfile.Sync(context.Background(), SyncOptions{})
+	suite.Require().NoError(err)
+
+	lints, err = appfile.Lint(context.Background(), LintOptions{MaxCostIncrease: &maxCost})
+	suite.Require().NoError(err)
+	suite.Empty(lints[0].Errors)
+}
+
+func (suite *AppfileSuite) TestSyncDryRunDoesNotChangeApps() {
+	backend := fake.NewBackend()
+	appfile := suite.nestedAppfile(backend)
+
+	_, err := appfile.Sync(context.Background(), SyncOptions{DryRun: true})
+	suite.Require().NoError(err)
+
+	suite.Empty(backend.Apps())
+}
+
+func (suite *AppfileSuite) TestSyncRefusesUnmanagedApps() {
+	backend := fake.NewBackend()
+	_, err := backend.AddApp(&godo.AppSpec{Name: "team-a-review"})
+	suite.Require().NoError(err)
+	appfile := suite.nestedAppfile(backend)
+
+	_, err = appfile.Sync(context.Background(), SyncOptions{})
+	suite.EqualError(err, "App team-a-review was not created by appfile. Use --adopt to manage it anyway")
+	suite.Equal(apperrors.KindDeployment, apperrors.KindOf(err))
+
+	_, err = appfile.Sync(context.Background(), SyncOptions{Adopt: true})
+	suite.Require().NoError(err)
+	suite.Len(backend.Apps(), 2)
+}
+
+func (suite *AppfileSuite) TestSyncPrunesUndeclaredApps() {
+	backend := fake.NewBackend()
+	stale := &AppSpec{AppSpec: &godo.AppSpec{Name: "stale-review"}}
+	stale.SetOwner(Owner{Project: "nested", Environment: "review"})
+	_, err := backend.AddApp(stale.AppSpec)
+	suite.Require().NoError(err)
+	appfile := suite.nestedAppfile(backend)
+
+	_, err = appfile.Sync(context.Background(), SyncOptions{})
+	suite.Require().NoError(err)
+	suite.Len(backend.Apps(), 3)
+
+	_, err = appfile.Sync(context.Background(), SyncOptions{Prune: true})
+	suite.Require().NoError(err)
+
+	names := []string{}
+	for _, app := range backend.Apps() {
+		names = append(names, app.Spec.Name)
+	}
+	suite.ElementsMatch([]string{"team-a-review", "team-b-staging-platform"}, names)
+}
+
+func (suite *AppfileSuite) TestSyncStopsStartingNewOperations() {
+	backend := fake.NewBackend()
+	appfile := suite.nestedAppfile(backend)
+
+	ctx, stop := interrupt.WithStop(context.Background())
+	stop()
+
+	summary, err := appfile.Sync(ctx, SyncOptions{})
+
+	suite.EqualError(err, "Sync interrupted before syncing apps: team-a-review, team-b-staging-platform")
+	suite.True(summary.Interrupted)
+	suite.Equal(2, summary.Count(ResultSkipped))
+	suite.Empty(backend.Apps())
+}
+
+func (suite *AppfileSuite) TestSyncFailsWhenContextIsCancelled() {
+	backend := fake.NewBackend()
+	appfile := suite.nestedAppfile(backend)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := appfile.Sync(ctx, SyncOptions{})
+
+	suite.Error(err)
+	suite.True(errors.Is(err, context.Canceled))
+}
+
+func (suite *AppfileSuite) TestDestroyDeletesAppsAndRecords() {
+	backend := fake.NewBackend()
+	appSpec := &AppSpec{AppSpec: &godo.AppSpec{
+		Name: "web",
+		Domains: []*godo.AppDomainSpec{
+			{Domain: "web.example.com", Zone: "example.com"},
+		},
+	}}
+	appfile, err := NewAppfileFromAppSpec(appSpec, backend, auth.NewStaticResolver("token"))
+	suite.Require().NoError(err)
+
+	_, err = appfile.Sync(context.Background(), SyncOptions{})
+	suite.Require().NoError(err)
+	suite.Len(backend.Records("example.com"), 1)
+
+	_, err = appfile.Destroy(context.Background(), DestroyOptions{})
+	suite.Require().NoError(err)
+	suite.Empty(backend.Apps())
+	suite.Empty(backend.Records("example.com"))
+
+	_, err = appfile.Destroy(context.Background(), DestroyOptions{})
+	suite.EqualError(err, "No app to destroy with name web")
+	suite.Equal(apperrors.KindNotFound, apperrors.KindOf(err))
+}
+
+func (suite *AppfileSuite) TestSyncUsesTheTokenOfEachApp() {
+	os.Setenv("STAGING_TOKEN", "staging-token")
+	os.Setenv("PRODUCTION_TOKEN", "production-token")
+	os.Setenv("WORKER_TOKEN", "worker-token")
+	defer os.Unsetenv("STAGING_TOKEN")
+	defer os.Unsetenv("PRODUCTION_TOKEN")
+	defer os.Unsetenv("WORKER_TOKEN")
+
+	backend := tokenBackend{}
+	spec, err := ReadAppfileSpec("../../testdata/multi_account/appfile.yaml")
+	suite.Require().NoError(err)
+
+	for _, envName := range []string{"staging", "production"} {
+		appfile, err := NewAppfileFromSpec(spec, envName, backend, auth.NewStaticResolver("default-token"))
+		suite.Require().NoError(err)
+
+		_, err = appfile.Sync(context.Background(), SyncOptions{})
+		suite.Require().NoError(err)
+	}
+
+	suite.Equal([]string{"web-staging"}, appNames(backend["staging-token"].Apps()))
+	suite.Equal([]string{"web-production"}, appNames(backend["production-token"].Apps()))
+	suite.Equal([]string{"worker-staging", "worker-production"}, appNames(backend["worker-token"].Apps()))
+	suite.NotContains(backend, "default-token")
+}
+
+func (suite *AppfileSuite) TestMissingTokenEnvVar() {
+	spec, err := ReadAppfileSpec("../../testdata/multi_account/appfile.yaml")
+	suite.Require().NoError(err)
+
+	_, err = NewAppfileFromSpec(spec, "production", tokenBackend{}, auth.NewStaticResolver("default-token"))
+
+	suite.Error(err)
+	suite.Contains(err.Error(), "Environment variable PRODUCTION_TOKEN holding the access token is not set")
+	suite.Equal(apperrors.KindAuth, apperrors.KindOf(err))
+}
+
+// tokenBackend simulates a separate account for every access token
+type tokenBackend map[string]*fake.Backend
+
+func (backend tokenBackend) account(token string) *fake.Backend {
+	if _, ok := backend[token]; !ok {
+		backend[token] = fake.NewBackend()
+	}
+
+	return backend[token]
+}
+
+func (backend tokenBackend) AppService(token string) do.AppService {
+	return backend.account(token).AppService(token)
+}
+
+func (backend tokenBackend) DomainService(token string) do.DomainService {
+	return backend.account(token).DomainService(token)
+}
+
+func (suite *AppfileSuite) nestedAppfile(backend *fake.Backend) *Appfile {
+	spec, err := ReadAppfileSpec("../../testdata/nested/appfile.yaml")
+	suite.Require().NoError(err)
+
+	appfile, err := NewAppfileFromSpec(spec, "review", backend, auth.NewStaticResolver("token"))
+	suite.Require().NoError(err)
+
+	return appfile
+}
+
+func TestAppfileSuite(t *testing.T) {
+	suite.Run(t, &AppfileSuite{})
+}
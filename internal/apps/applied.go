@@ -0,0 +1,216 @@
+package apps
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/digitalocean/godo"
+	"github.com/pkg/errors"
+	apperrors "github.com/renehernandez/appfile/internal/errors"
+	"github.com/sergi/go-diff/diffmatchpatch"
+)
+
+// DefaultAppliedStateFile is the name of the file recording the apps synced by
+// appfile, next to the appfile
+const DefaultAppliedStateFile = ".appfile-state.json"
+
+// Policies on apps changed in DigitalOcean since they were last synced
+const (
+	// DriftFail refuses to sync when any app changed
+	DriftFail = "fail"
+	// DriftWarn reports the changes and overwrites them
+	DriftWarn = "warn"
+	// DriftOverwrite overwrites the changes without reporting them
+	DriftOverwrite = "overwrite"
+)
+
+// appliedStateVersion is the version of the format of the applied state file
+const appliedStateVersion = 1
+
+// AppliedState records, for every environment, the apps as they were last
+// synced by appfile, to detect the changes made to them out of band
+type AppliedState struct {
+	Version      int                               `json:"version"`
+	Environments map[string]map[string]*AppliedApp `json:"environments"`
+}
+
+// AppliedApp is an app as returned by DigitalOcean when it was last synced
+type AppliedApp struct {
+	ID          string        `json:"id"`
+	Fingerprint string        `json:"fingerprint"`
+	AppliedAt   time.Time     `json:"applied_at"`
+	Spec        *godo.AppSpec `json:"spec"`
+}
+
+// AppDrift reports an app that changed in DigitalOcean since it was last synced
+type AppDrift struct {
+	Name string
+	// Changes holds the out-of-band changes as a diff of the spec last synced
+	// against the remote spec
+	Changes string
+}
+
+// NewAppliedState returns an empty applied state
+func NewAppliedState() *AppliedState {
+	return &AppliedState{
+		Version:      appliedStateVersion,
+		Environments: map[string]map[string]*AppliedApp{},
+	}
+}
+
+// ReadAppliedState reads the applied state from file. A missing file is an empty state
+func ReadAppliedState(file string) (*AppliedState, error) {
+	content, err := ioutil.ReadFile(file)
+	if os.IsNotExist(err) {
+		return NewAppliedState(), nil
+	}
+	if err != nil {
+		return &AppliedState{}, apperrors.Wrap(apperrors.KindConfig, errors.Wrapf(err, "Failed to read applied state from %s", file))
+	}
+
+	state := NewAppliedState()
+	if err := json.Unmarshal(content, state); err != nil {
+		return &AppliedState{}, apperrors.Wrap(apperrors.KindConfig, errors.Wrapf(err, "Failed to parse applied state from %s", file))
+	}
+
+	if state.Version != appliedStateVersion {
+		return &AppliedState{}, apperrors.New(apperrors.KindConfig, "Unsupported version %d of applied state %s, expected %d", state.Version, file, appliedStateVersion)
+	}
+
+	if state.Environments == nil {
+		state.Environments = map[string]map[string]*AppliedApp{}
+	}
+
+	return state, nil
+}
+
+// Write writes the applied state to file
+func (state *AppliedState) Write(file string) error {
+	content, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "Failed to serialize applied state")
+	}
+
+	if err := ioutil.WriteFile(file, append(content, '\n'), 0600); err != nil {
+		return apperrors.Wrap(apperrors.KindConfig, errors.Wrapf(err, "Failed to write applied state to %s", file))
+	}
+
+	return nil
+}
+
+// Lookup returns the app of the environment as it was last synced, if any
+func (state *AppliedState) Lookup(envName string, name string) (*AppliedApp, bool) {
+	applied, ok := state.Environments[envName][name]
+
+	return applied, ok
+}
+
+// record stores the app returned by DigitalOcean after syncing it
+func (state *AppliedState) record(envName string, app *godo.App) error {
+	fingerprint, err := Fingerprint(app.Spec)
+	if err != nil {
+		return err
+	}
+
+	spec, err := copySpec(app.Spec)
+	if err != nil {
+		return err
+	}
+
+	if _, ok := state.Environments[envName]; !ok {
+		state.Environments[envName] = map[string]*AppliedApp{}
+	}
+
+	state.Environments[envName][app.Spec.Name] = &AppliedApp{
+		ID:          app.ID,
+		Fingerprint: fingerprint,
+		AppliedAt:   time.Now().UTC(),
+		Spec:        spec,
+	}
+
+	return nil
+}
+
+// forget removes the app of the environment from the state
+func (state *AppliedState) forget(envName string, name string) {
+	delete(state.Environments[envName], name)
+}
+
+// Drift returns the changes made to the remote app since it was last synced,
+// or nil if it didn't change or was never synced
+func (state *AppliedState) Drift(envName string, remoteApp *godo.App) (*AppDrift, error) {
+	applied, ok := state.Lookup(envName, remoteApp.Spec.Name)
+	if !ok {
+		return nil, nil
+	}
+
+	fingerprint, err := Fingerprint(remoteApp.Spec)
+	if err != nil {
+		return nil, err
+	}
+
+	if applied.ID == remoteApp.ID && applied.Fingerprint == fingerprint {
+		return nil, nil
+	}
+
+	comparison, err := CompareSpecs(applied.Spec, remoteApp.Spec)
+	if err != nil {
+		return nil, err
+	}
+
+	if applied.ID == remoteApp.ID && comparison.Equal {
+		return nil, nil
+	}
+
+	changes, err := specChanges(applied.Spec, remoteApp.Spec)
+	if err != nil {
+		return nil, err
+	}
+
+	return &AppDrift{
+		Name:    remoteApp.Spec.Name,
+		Changes: changes,
+	}, nil
+}
+
+// specChanges returns the lines that differ between the specs, prefixed
+// with - when removed from the first one and + when added to the second one
+func specChanges(from *godo.AppSpec, to *godo.AppSpec) (string, error) {
+	fromYaml, err := appSpecToString(from)
+	if err != nil {
+		return "", err
+	}
+
+	toYaml, err := appSpecToString(to)
+	if err != nil {
+		return "", err
+	}
+
+	dmp := diffmatchpatch.New()
+	fromChars, toChars, lines := dmp.DiffLinesToChars(fromYaml, toYaml)
+	diffs := dmp.DiffCharsToLines(dmp.DiffMain(fromChars, toChars, false), lines)
+
+	var changes strings.Builder
+	for _, diff := range diffs {
+		prefix := ""
+		switch diff.Type {
+		case diffmatchpatch.DiffDelete:
+			prefix = "- "
+		case diffmatchpatch.DiffInsert:
+			prefix = "+ "
+		default:
+			continue
+		}
+
+		for _, line := range strings.SplitAfter(diff.Text, "\n") {
+			if line != "" {
+				changes.WriteString(prefix + strings.TrimSuffix(line, "\n") + "\n")
+			}
+		}
+	}
+
+	return changes.String(), nil
+}
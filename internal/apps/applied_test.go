@@ -0,0 +1,121 @@
+package apps
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/digitalocean/godo"
+	"github.com/renehernandez/appfile/internal/auth"
+	"github.com/renehernandez/appfile/internal/do/fake"
+	apperrors "github.com/renehernandez/appfile/internal/errors"
+	"github.com/stretchr/testify/suite"
+)
+
+type AppliedSuite struct {
+	suite.Suite
+
+	backend *fake.Backend
+	appfile *Appfile
+}
+
+func (suite *AppliedSuite) SetupTest() {
+	suite.backend = fake.NewBackend()
+
+	spec, err := ReadAppfileSpec("../../testdata/nested/appfile.yaml")
+	suite.Require().NoError(err)
+
+	suite.appfile, err = NewAppfileFromSpec(spec, "review", suite.backend, auth.NewStaticResolver("token"))
+	suite.Require().NoError(err)
+	suite.appfile.Applied = NewAppliedState()
+
+	_, err = suite.appfile.Sync(context.Background(), SyncOptions{})
+	suite.Require().NoError(err)
+}
+
+func (suite *AppliedSuite) changeRemote() {
+	remote := suite.backend.Apps()[0]
+	changed := *remote.Spec
+	changed.Region = "ams"
+	_, err := suite.backend.AppService("token").Update(context.Background(), &godo.App{Spec: &changed}, remote)
+	suite.Require().NoError(err)
+}
+
+func (suite *AppliedSuite) TestSyncRecordsApps() {
+	for _, app := range suite.backend.Apps() {
+		applied, ok := suite.appfile.Applied.Lookup("review", app.Spec.Name)
+		suite.Require().True(ok)
+		suite.Equal(app.ID, applied.ID)
+
+		fingerprint, err := Fingerprint(app.Spec)
+		suite.Require().NoError(err)
+		suite.Equal(fingerprint, applied.Fingerprint)
+	}
+}
+
+func (suite *AppliedSuite) TestReadWriteAppliedState() {
+	file := filepath.Join(suite.T().TempDir(), DefaultAppliedStateFile)
+
+	state, err := ReadAppliedState(file)
+	suite.Require().NoError(err)
+	suite.Empty(state.Environments)
+
+	suite.Require().NoError(suite.appfile.Applied.Write(file))
+	state, err = ReadAppliedState(file)
+	suite.Require().NoError(err)
+	suite.Equal(suite.appfile.Applied.Environments["review"]["team-a-review"].Fingerprint, state.Environments["review"]["team-a-review"].Fingerprint)
+}
+
+func (suite *AppliedSuite) TestDriftShowsOutOfBandChanges() {
+	remote := suite.backend.Apps()[0]
+	drift, err := suite.appfile.Applied.Drift("review", remote)
+	suite.Require().NoError(err)
+	suite.Nil(drift)
+
+	suite.changeRemote()
+	drift, err = suite.appfile.Applied.Drift("review", suite.backend.Apps()[0])
+	suite.Require().NoError(err)
+	suite.Require().NotNil(drift)
+	suite.Contains(drift.Changes, "+ region: ams")
+}
+
+func (suite *AppliedSuite) TestSyncFailsOnDrift() {
+	suite.changeRemote()
+
+	summary, err := suite.appfile.Sync(context.Background(), SyncOptions{})
+	suite.Require().Error(err)
+	suite.Contains(err.Error(), "Apps changed in DigitalOcean since they were last synced: "+suite.backend.Apps()[0].Spec.Name)
+	suite.Equal(apperrors.KindDeployment, apperrors.KindOf(err))
+	suite.Empty(summary.Results)
+	suite.Equal("ams", suite.backend.Apps()[0].Spec.Region)
+}
+
+func (suite *AppliedSuite) TestSyncOverwritesDrift() {
+	for _, policy := range []string{DriftWarn, DriftOverwrite} {
+		suite.changeRemote()
+
+		summary, err := suite.appfile.Sync(context.Background(), SyncOptions{OnDrift: policy})
+		suite.Require().NoError(err)
+		suite.Equal(1, summary.Count(ResultUpdated))
+		suite.NotEqual("ams", suite.backend.Apps()[0].Spec.Region)
+	}
+}
+
+func (suite *AppliedSuite) TestPlanFailsOnDrift() {
+	suite.changeRemote()
+
+	_, err := suite.appfile.Plan(context.Background(), PlanOptions{})
+	suite.Require().Error(err)
+	suite.Contains(err.Error(), "Apps changed in DigitalOcean since they were last synced: "+suite.backend.Apps()[0].Spec.Name)
+	suite.Equal(apperrors.KindDeployment, apperrors.KindOf(err))
+
+	for _, policy := range []string{DriftWarn, DriftOverwrite} {
+		plan, err := suite.appfile.Plan(context.Background(), PlanOptions{OnDrift: policy})
+		suite.Require().NoError(err)
+		suite.Equal(ActionUpdate, plan.Apps[0].Action)
+	}
+}
+
+func TestAppliedSuite(t *testing.T) {
+	suite.Run(t, &AppliedSuite{})
+}
@@ -0,0 +1,196 @@
+package apps
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/digitalocean/godo"
+	"github.com/pkg/errors"
+)
+
+// encryptedValuePrefix starts the values of the secrets returned by DigitalOcean
+const encryptedValuePrefix = "EV["
+
+// SpecComparison is the result of comparing a local spec with the remote one
+type SpecComparison struct {
+	// Equal is set when the specs only differ in values DigitalOcean doesn't
+	// return as sent, such as empty fields, ordering and encrypted secrets
+	Equal bool
+	// MaskedSecrets lists the keys of the secrets whose values could not be
+	// compared because DigitalOcean returns them encrypted
+	MaskedSecrets []string
+}
+
+// CompareSpecs compares the local spec with the remote one after normalizing
+// both: empty values are dropped, env vars and domains are sorted, and the
+// values of secrets returned encrypted are ignored
+func CompareSpecs(local *godo.AppSpec, remote *godo.AppSpec) (*SpecComparison, error) {
+	localCopy, err := copySpec(local)
+	if err != nil {
+		return &SpecComparison{}, err
+	}
+
+	remoteCopy, err := copySpec(remote)
+	if err != nil {
+		return &SpecComparison{}, err
+	}
+
+	comparison := &SpecComparison{
+		MaskedSecrets: maskEncryptedSecrets(localCopy, remoteCopy),
+	}
+
+	localNormalized, err := normalizeSpec(localCopy)
+	if err != nil {
+		return &SpecComparison{}, err
+	}
+
+	remoteNormalized, err := normalizeSpec(remoteCopy)
+	if err != nil {
+		return &SpecComparison{}, err
+	}
+
+	comparison.Equal = reflect.DeepEqual(localNormalized, remoteNormalized)
+
+	return comparison, nil
+}
+
+func copySpec(spec *godo.AppSpec) (*godo.AppSpec, error) {
+	copied := &godo.AppSpec{}
+	if spec == nil {
+		return copied, nil
+	}
+
+	content, err := json.Marshal(spec)
+	if err != nil {
+		return copied, errors.Wrapf(err, "Failed to copy spec of app %s", spec.Name)
+	}
+
+	if err := json.Unmarshal(content, copied); err != nil {
+		return copied, errors.Wrapf(err, "Failed to copy spec of app %s", spec.Name)
+	}
+
+	return copied, nil
+}
+
+// maskEncryptedSecrets clears the values of the secrets that DigitalOcean
+// returned encrypted in both specs, returning their keys
+func maskEncryptedSecrets(local *godo.AppSpec, remote *godo.AppSpec) []string {
+	masked := []string{}
+	localLists := envLists(local)
+
+	for component, remoteEnvs := range envLists(remote) {
+		for _, remoteEnv := range remoteEnvs {
+			if remoteEnv.Type != godo.AppVariableType_Secret || !strings.HasPrefix(remoteEnv.Value, encryptedValuePrefix) {
+				continue
+			}
+
+			remoteEnv.Value = ""
+			for _, localEnv := range localLists[component] {
+				if localEnv.Key == remoteEnv.Key && localEnv.Type == godo.AppVariableType_Secret {
+					localEnv.Value = ""
+				}
+			}
+			masked = append(masked, remoteEnv.Key)
+		}
+	}
+
+	sort.Strings(masked)
+
+	return masked
+}
+
+// envLists returns the env vars of the app and of each of its components
+func envLists(spec *godo.AppSpec) map[string][]*godo.AppVariableDefinition {
+	lists := map[string][]*godo.AppVariableDefinition{
+		"": spec.Envs,
+	}
+
+	for _, service := range spec.Services {
+		lists["services."+service.Name] = service.Envs
+	}
+	for _, site := range spec.StaticSites {
+		lists["static_sites."+site.Name] = site.Envs
+	}
+	for _, worker := range spec.Workers {
+		lists["workers."+worker.Name] = worker.Envs
+	}
+	for _, job := range spec.Jobs {
+		lists["jobs."+job.Name] = job.Envs
+	}
+
+	return lists
+}
+
+// normalizeSpec returns the spec as plain JSON values without empty values,
+// with the env vars sorted by key and the domains sorted by name
+func normalizeSpec(spec *godo.AppSpec) (interface{}, error) {
+	content, err := json.Marshal(spec)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to normalize spec of app %s", spec.Name)
+	}
+
+	var value interface{}
+	if err := json.Unmarshal(content, &value); err != nil {
+		return nil, errors.Wrapf(err, "Failed to normalize spec of app %s", spec.Name)
+	}
+
+	normalized, _ := normalizeValue(value, "")
+
+	return normalized, nil
+}
+
+// sortKeys holds the field sorting the items of the lists that DigitalOcean
+// doesn't return in a stable order
+var sortKeys = map[string]string{
+	"envs":    "key",
+	"domains": "domain",
+}
+
+// normalizeValue drops the empty values, returning false if the value itself is empty
+func normalizeValue(value interface{}, key string) (interface{}, bool) {
+	switch typed := value.(type) {
+	case nil:
+		return nil, false
+	case string:
+		return typed, typed != ""
+	case bool:
+		return typed, typed
+	case float64:
+		return typed, typed != 0
+	case map[string]interface{}:
+		normalized := map[string]interface{}{}
+		for k, v := range typed {
+			if nv, ok := normalizeValue(v, k); ok {
+				normalized[k] = nv
+			}
+		}
+		return normalized, len(normalized) > 0
+	case []interface{}:
+		normalized := []interface{}{}
+		for _, v := range typed {
+			if nv, ok := normalizeValue(v, ""); ok {
+				normalized = append(normalized, nv)
+			}
+		}
+		if sortKey, ok := sortKeys[key]; ok {
+			sort.SliceStable(normalized, func(i, j int) bool {
+				return sortValue(normalized[i], sortKey) < sortValue(normalized[j], sortKey)
+			})
+		}
+		return normalized, len(normalized) > 0
+	}
+
+	return value, true
+}
+
+func sortValue(item interface{}, sortKey string) string {
+	if fields, ok := item.(map[string]interface{}); ok {
+		if value, ok := fields[sortKey].(string); ok {
+			return value
+		}
+	}
+
+	return ""
+}
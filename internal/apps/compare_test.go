@@ -0,0 +1,79 @@
+package apps
+
+import (
+	"testing"
+
+	"github.com/digitalocean/godo"
+	"github.com/stretchr/testify/suite"
+)
+
+type CompareSuite struct {
+	suite.Suite
+}
+
+func (suite *CompareSuite) TestIgnoresEmptyValuesAndOrdering() {
+	local := &godo.AppSpec{
+		Name: "web",
+		Envs: []*godo.AppVariableDefinition{
+			{Key: "B", Value: "2"},
+			{Key: "A", Value: "1"},
+		},
+		Services: []*godo.AppServiceSpec{
+			{Name: "web", InstanceCount: 1, Routes: []*godo.AppRouteSpec{}},
+		},
+	}
+	remote := &godo.AppSpec{
+		Name: "web",
+		Envs: []*godo.AppVariableDefinition{
+			{Key: "A", Value: "1"},
+			{Key: "B", Value: "2"},
+		},
+		Services: []*godo.AppServiceSpec{
+			{Name: "web", InstanceCount: 1},
+		},
+	}
+
+	comparison, err := CompareSpecs(local, remote)
+
+	suite.NoError(err)
+	suite.True(comparison.Equal)
+	suite.Empty(comparison.MaskedSecrets)
+}
+
+func (suite *CompareSuite) TestDetectsChanges() {
+	local := &godo.AppSpec{Name: "web", Services: []*godo.AppServiceSpec{{Name: "web", InstanceCount: 2}}}
+	remote := &godo.AppSpec{Name: "web", Services: []*godo.AppServiceSpec{{Name: "web", InstanceCount: 1}}}
+
+	comparison, err := CompareSpecs(local, remote)
+
+	suite.NoError(err)
+	suite.False(comparison.Equal)
+}
+
+func (suite *CompareSuite) TestMasksEncryptedSecrets() {
+	local := &godo.AppSpec{
+		Name: "web",
+		Services: []*godo.AppServiceSpec{{
+			Name: "web",
+			Envs: []*godo.AppVariableDefinition{{Key: "TOKEN", Value: "plain", Type: godo.AppVariableType_Secret}},
+		}},
+	}
+	remote := &godo.AppSpec{
+		Name: "web",
+		Services: []*godo.AppServiceSpec{{
+			Name: "web",
+			Envs: []*godo.AppVariableDefinition{{Key: "TOKEN", Value: "EV[1:abc]", Type: godo.AppVariableType_Secret}},
+		}},
+	}
+
+	comparison, err := CompareSpecs(local, remote)
+
+	suite.NoError(err)
+	suite.True(comparison.Equal)
+	suite.Equal([]string{"TOKEN"}, comparison.MaskedSecrets)
+	suite.Equal("plain", local.Services[0].Envs[0].Value)
+}
+
+func TestCompareSuite(t *testing.T) {
+	suite.Run(t, &CompareSuite{})
+}
@@ -0,0 +1,293 @@
+package apps
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/renehernandez/appfile/internal/auth"
+	"github.com/renehernandez/appfile/internal/env"
+	"github.com/renehernandez/appfile/internal/log"
+	"github.com/renehernandez/appfile/internal/tmpl"
+	"github.com/renehernandez/appfile/internal/yaml"
+)
+
+// EnvironmentSpec declares the values of an environment in the appfile spec.
+// It can be written as a plain list of values files or as a map
+type EnvironmentSpec struct {
+	Extends []string       `yaml:"extends"`
+	Values  []*ValuesEntry `yaml:"values"`
+	// Token is the source of the access token for the apps of the environment
+	Token *auth.Source `yaml:"token"`
+}
+
+func (envSpec *EnvironmentSpec) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var values []*ValuesEntry
+	if err := unmarshal(&values); err == nil {
+		envSpec.Values = values
+		return nil
+	}
+
+	type rawEnvironmentSpec EnvironmentSpec
+	var raw rawEnvironmentSpec
+	if err := unmarshal(&raw); err != nil {
+		return err
+	}
+
+	*envSpec = EnvironmentSpec(raw)
+	return nil
+}
+
+// MergeStrategySpec declares how lists are merged when combining environment values.
+// It can be written as a single strategy for every list or as a map
+type MergeStrategySpec struct {
+	Default string            `yaml:"default"`
+	Keys    map[string]string `yaml:"keys"`
+}
+
+func (strategy *MergeStrategySpec) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var value string
+	if err := unmarshal(&value); err == nil {
+		strategy.Default = value
+		return nil
+	}
+
+	type rawMergeStrategySpec MergeStrategySpec
+	var raw rawMergeStrategySpec
+	if err := unmarshal(&raw); err != nil {
+		return err
+	}
+
+	*strategy = MergeStrategySpec(raw)
+	return nil
+}
+
+func (strategy *MergeStrategySpec) options() (*env.MergeOptions, error) {
+	opts := &env.MergeOptions{
+		Keys: map[string]env.MergeStrategy{},
+	}
+
+	if strategy.Default != "" {
+		defaultStrategy, err := env.ParseMergeStrategy(strategy.Default)
+		if err != nil {
+			return nil, err
+		}
+		opts.Default = defaultStrategy
+	}
+
+	for key, value := range strategy.Keys {
+		keyStrategy, err := env.ParseMergeStrategy(value)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Invalid merge strategy for key %s", key)
+		}
+		opts.Keys[key] = keyStrategy
+	}
+
+	return opts, nil
+}
+
+// ValuesFromEnvSpec configures how values are read from environment variables
+type ValuesFromEnvSpec struct {
+	Prefix    string `yaml:"prefix"`
+	Separator string `yaml:"separator"`
+}
+
+func (valuesFromEnv *ValuesFromEnvSpec) prefix() string {
+	if valuesFromEnv == nil || valuesFromEnv.Prefix == "" {
+		return env.DefaultValuesPrefix
+	}
+
+	return valuesFromEnv.Prefix
+}
+
+func (valuesFromEnv *ValuesFromEnvSpec) separator() string {
+	if valuesFromEnv == nil || valuesFromEnv.Separator == "" {
+		return env.DefaultValuesSeparator
+	}
+
+	return valuesFromEnv.Separator
+}
+
+// ValuesEntry is either the path to a values file or an inline map of values
+type ValuesEntry struct {
+	Path   string
+	Inline map[string]interface{}
+}
+
+func (entry *ValuesEntry) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var path string
+	if err := unmarshal(&path); err == nil {
+		entry.Path = path
+		return nil
+	}
+
+	var inline map[string]interface{}
+	if err := unmarshal(&inline); err != nil {
+		return err
+	}
+
+	entry.Inline = inline
+	return nil
+}
+
+// read returns the values of the entry. Paths are relative to baseDir
+func (entry *ValuesEntry) read(baseDir string, envName string) (*env.Environment, error) {
+	if entry.Path == "" {
+		log.Debugf("Reading inline environment values in env %s", envName)
+		return &env.Environment{Values: entry.Inline}, nil
+	}
+
+	file := filepath.Join(baseDir, entry.Path)
+	log.Debugf("Reading environment values from %s", file)
+	templatedYaml, err := tmpl.RenderFromFile(file)
+	if err != nil {
+		return &env.Environment{}, err
+	}
+
+	envPart, err := yaml.ParseEnvironment(templatedYaml)
+	if err != nil {
+		return &env.Environment{}, errors.Wrapf(err, "Could not parse resulting yaml from file %s in env %s", file, envName)
+	}
+
+	return envPart, nil
+}
+
+func (entry *ValuesEntry) String() string {
+	if entry.Path == "" {
+		return "inline values"
+	}
+
+	return fmt.Sprintf("file %s", entry.Path)
+}
+
+// mergeOptions returns the merge strategies declared in the appfile spec, or nil
+// to replace lists
+func (spec *AppfileSpec) mergeOptions() (*env.MergeOptions, error) {
+	if spec.MergeStrategy == nil {
+		return nil, nil
+	}
+
+	opts, err := spec.MergeStrategy.options()
+	if err != nil {
+		return nil, errors.Wrapf(err, "Invalid mergeStrategy in appfile spec at %s", spec.Path())
+	}
+
+	return opts, nil
+}
+
+// resolveEnvironment merges the values of the environment on top of the values
+// of the environments it extends, in the order they are declared
+func (spec *AppfileSpec) resolveEnvironment(name string, chain []string) (*env.Environment, error) {
+	for _, visited := range chain {
+		if visited == name {
+			return &env.Environment{}, fmt.Errorf("Environment cycle detected: %s", strings.Join(append(chain, name), " -> "))
+		}
+	}
+	chain = append(chain, name)
+
+	envSpec, ok := spec.Environments[name]
+	if !ok {
+		return &env.Environment{}, fmt.Errorf("Environment %s extended by %s not found in appfile spec at %s", name, chain[len(chain)-2], spec.Path())
+	}
+
+	fullEnv := &env.Environment{
+		Name: name,
+	}
+
+	if envSpec == nil {
+		return fullEnv, nil
+	}
+
+	mergeOpts, err := spec.mergeOptions()
+	if err != nil {
+		return &env.Environment{}, err
+	}
+
+	for _, base := range envSpec.Extends {
+		log.Debugf("Environment %s extends environment %s", name, base)
+		baseEnv, err := spec.resolveEnvironment(base, chain)
+		if err != nil {
+			return &env.Environment{}, err
+		}
+
+		fullEnv, err = fullEnv.MergeWithOptions(baseEnv, mergeOpts)
+		if err != nil {
+			return &env.Environment{}, errors.Wrapf(err, "Could not merge values from env %s in env %s", base, name)
+		}
+	}
+
+	for _, entry := range envSpec.Values {
+		envPart, err := entry.read(filepath.Dir(spec.Path()), name)
+		if err != nil {
+			return &env.Environment{}, err
+		}
+
+		fullEnv, err = fullEnv.MergeWithOptions(envPart, mergeOpts)
+		if err != nil {
+			return &env.Environment{}, errors.Wrapf(err, "Could not merge values from %s in env %s", entry, name)
+		}
+	}
+
+	fullEnv.Name = name
+
+	return fullEnv, nil
+}
+
+// mergeValuesFromEnv merges the values declared by the prefixed environment
+// variables on top of the environment values
+func (spec *AppfileSpec) mergeValuesFromEnv(fullEnv *env.Environment) (*env.Environment, error) {
+	prefix := spec.ValuesFromEnv.prefix()
+	envPart, overridden, err := env.FromVars(fullEnv.Name, os.Environ(), prefix, spec.ValuesFromEnv.separator())
+	if err != nil {
+		return &env.Environment{}, err
+	}
+
+	if len(overridden) == 0 {
+		return fullEnv, nil
+	}
+
+	for _, value := range overridden {
+		log.Debugf("Value %s in env %s overridden by environment variable %s", value.Key, fullEnv.Name, value.Var)
+	}
+
+	merged, err := fullEnv.Merge(envPart)
+	if err != nil {
+		return &env.Environment{}, errors.Wrapf(err, "Could not merge values from environment variables with prefix %s in env %s", prefix, fullEnv.Name)
+	}
+
+	return merged, nil
+}
+
+// environmentToken returns the token source of the environment or, when it
+// doesn't declare one, of the first environment it extends declaring one
+func (spec *AppfileSpec) environmentToken(name string, chain []string) (*auth.Source, error) {
+	for _, visited := range chain {
+		if visited == name {
+			return nil, fmt.Errorf("Environment cycle detected: %s", strings.Join(append(chain, name), " -> "))
+		}
+	}
+	chain = append(chain, name)
+
+	envSpec := spec.Environments[name]
+	if envSpec == nil {
+		return nil, nil
+	}
+
+	if envSpec.Token != nil {
+		if err := envSpec.Token.Validate(); err != nil {
+			return nil, errors.Wrapf(err, "Invalid token of env %s", name)
+		}
+		return envSpec.Token, nil
+	}
+
+	for _, base := range envSpec.Extends {
+		token, err := spec.environmentToken(base, chain)
+		if err != nil || token != nil {
+			return token, err
+		}
+	}
+
+	return nil, nil
+}
@@ -0,0 +1,171 @@
+package apps
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// expandSpecPath resolves an entry of the specs list, relative to baseDir, into
+// the sorted list of app spec files it references. The entry can be a file,
+// a directory, whose yaml files are included, or a glob pattern supporting **
+// to match any number of directories
+func expandSpecPath(baseDir string, pattern string) ([]string, error) {
+	fullPattern := pattern
+	if !filepath.IsAbs(fullPattern) {
+		fullPattern = filepath.Join(baseDir, pattern)
+	}
+
+	var files []string
+	var err error
+
+	switch {
+	case isGlobPattern(pattern):
+		files, err = globFiles(fullPattern)
+	case isDir(fullPattern):
+		files, err = yamlFilesInDir(fullPattern)
+	default:
+		return []string{fullPattern}, nil
+	}
+
+	if err != nil {
+		return []string{}, errors.Wrapf(err, "Could not expand specs entry %s", pattern)
+	}
+
+	if len(files) == 0 {
+		return []string{}, fmt.Errorf("Specs entry %s did not match any file", pattern)
+	}
+
+	sort.Strings(files)
+
+	return files, nil
+}
+
+func isGlobPattern(pattern string) bool {
+	return strings.ContainsAny(pattern, "*?[")
+}
+
+func isDir(name string) bool {
+	info, err := os.Stat(name)
+	return err == nil && info.IsDir()
+}
+
+func isYamlFile(name string) bool {
+	ext := filepath.Ext(name)
+	return ext == ".yaml" || ext == ".yml"
+}
+
+func yamlFilesInDir(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return []string{}, err
+	}
+
+	files := []string{}
+	for _, entry := range entries {
+		if !entry.IsDir() && isYamlFile(entry.Name()) {
+			files = append(files, filepath.Join(dir, entry.Name()))
+		}
+	}
+
+	return files, nil
+}
+
+func globFiles(pattern string) ([]string, error) {
+	if !strings.Contains(pattern, "**") {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return []string{}, err
+		}
+
+		files := []string{}
+		for _, match := range matches {
+			if !isDir(match) {
+				files = append(files, match)
+			}
+		}
+
+		return files, nil
+	}
+
+	root := globRoot(pattern)
+	slashPattern := filepath.ToSlash(pattern)
+	files := []string{}
+
+	err := filepath.WalkDir(root, func(name string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if entry.IsDir() {
+			return nil
+		}
+
+		matched, err := matchDoublestar(slashPattern, filepath.ToSlash(name))
+		if err != nil {
+			return err
+		}
+
+		if matched {
+			files = append(files, name)
+		}
+
+		return nil
+	})
+
+	if os.IsNotExist(err) {
+		return []string{}, nil
+	}
+
+	return files, err
+}
+
+// globRoot returns the longest directory prefix of pattern without glob characters
+func globRoot(pattern string) string {
+	dir := pattern
+	for isGlobPattern(dir) {
+		dir = filepath.Dir(dir)
+	}
+
+	return dir
+}
+
+// matchDoublestar reports whether name matches pattern, where a ** segment
+// matches zero or more directories. Both must use forward slashes
+func matchDoublestar(pattern string, name string) (bool, error) {
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(name, "/"))
+}
+
+func matchSegments(patterns []string, names []string) (bool, error) {
+	for len(patterns) > 0 {
+		if patterns[0] == "**" {
+			for i := 0; i <= len(names); i++ {
+				matched, err := matchSegments(patterns[1:], names[i:])
+				if err != nil || matched {
+					return matched, err
+				}
+			}
+
+			return false, nil
+		}
+
+		if len(names) == 0 {
+			return false, nil
+		}
+
+		matched, err := path.Match(patterns[0], names[0])
+		if err != nil || !matched {
+			return false, err
+		}
+
+		patterns, names = patterns[1:], names[1:]
+	}
+
+	return len(names) == 0, nil
+}
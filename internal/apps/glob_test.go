@@ -0,0 +1,94 @@
+package apps
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type GlobSuite struct {
+	suite.Suite
+	baseDir string
+}
+
+func (suite *GlobSuite) SetupTest() {
+	suite.baseDir = filepath.Join("..", "..", "testdata", "glob")
+}
+
+func (suite *GlobSuite) TestLiteralFile() {
+	files, err := expandSpecPath(suite.baseDir, "apps/web/app.yaml")
+
+	suite.NoError(err)
+	suite.Equal([]string{suite.path("apps/web/app.yaml")}, files)
+}
+
+func (suite *GlobSuite) TestGlobPattern() {
+	files, err := expandSpecPath(suite.baseDir, "apps/*/app.yaml")
+
+	suite.NoError(err)
+	suite.Equal([]string{
+		suite.path("apps/api/app.yaml"),
+		suite.path("apps/web/app.yaml"),
+	}, files)
+}
+
+func (suite *GlobSuite) TestDoublestarPattern() {
+	files, err := expandSpecPath(suite.baseDir, "**/spec.yaml")
+
+	suite.NoError(err)
+	suite.Equal([]string{suite.path("apps/api/jobs/spec.yaml")}, files)
+
+	files, err = expandSpecPath(suite.baseDir, "apps/**/*.yaml")
+
+	suite.NoError(err)
+	suite.Equal([]string{
+		suite.path("apps/api/app.yaml"),
+		suite.path("apps/api/jobs/spec.yaml"),
+		suite.path("apps/web/app.yaml"),
+	}, files)
+}
+
+func (suite *GlobSuite) TestDirectory() {
+	files, err := expandSpecPath(suite.baseDir, "extra")
+
+	suite.NoError(err)
+	suite.Equal([]string{
+		suite.path("extra/web.yaml"),
+		suite.path("extra/worker.yml"),
+	}, files)
+}
+
+func (suite *GlobSuite) TestNoMatches() {
+	_, err := expandSpecPath(suite.baseDir, "apps/*/missing.yaml")
+	suite.EqualError(err, "Specs entry apps/*/missing.yaml did not match any file")
+
+	_, err = expandSpecPath(suite.baseDir, "empty")
+	suite.EqualError(err, "Specs entry empty did not match any file")
+}
+
+func (suite *GlobSuite) TestDuplicateNames() {
+	spec := &AppfileSpec{
+		AppSpecs: []*AppSpecEntry{
+			{Path: "apps/*/app.yaml"},
+			{Path: "extra"},
+		},
+	}
+	suite.Require().NoError(spec.SetPath(filepath.Join(suite.baseDir, "appfile.yaml")))
+
+	appSpecs, err := spec.loadAppSpecs(stateFor("default", nil), nil)
+	suite.Require().NoError(err)
+	suite.Len(appSpecs, 4)
+
+	err = checkDuplicateNames(appSpecs)
+	suite.Error(err)
+	suite.Contains(err.Error(), "App name web is declared by both")
+}
+
+func (suite *GlobSuite) path(name string) string {
+	return filepath.Join(suite.baseDir, filepath.FromSlash(name))
+}
+
+func TestGlobSuite(t *testing.T) {
+	suite.Run(t, &GlobSuite{})
+}
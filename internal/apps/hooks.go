@@ -0,0 +1,200 @@
+package apps
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	mapset "github.com/deckarep/golang-set"
+	"github.com/digitalocean/godo"
+	"github.com/pkg/errors"
+	"github.com/renehernandez/appfile/internal/log"
+)
+
+const (
+	HookEventPreSync     = "presync"
+	HookEventPostSync    = "postsync"
+	HookEventPreDestroy  = "predestroy"
+	HookEventPostDestroy = "postdestroy"
+	HookEventOnFailure   = "onfailure"
+)
+
+var hookEventsList = []interface{}{
+	HookEventPreSync,
+	HookEventPostSync,
+	HookEventPreDestroy,
+	HookEventPostDestroy,
+	HookEventOnFailure,
+}
+
+var hookEvents = mapset.NewSetFromSlice(hookEventsList)
+
+// Hook is a command run around the sync and destroy operations of an app
+type Hook struct {
+	Name     string   `yaml:"name"`
+	Events   []string `yaml:"events"`
+	Command  string   `yaml:"command"`
+	Args     []string `yaml:"args"`
+	ShowLogs bool     `yaml:"showlogs"`
+
+	dir string
+}
+
+func (hook *Hook) hasEvent(event string) bool {
+	for _, e := range hook.Events {
+		if e == event {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (hook *Hook) displayName() string {
+	if hook.Name != "" {
+		return hook.Name
+	}
+
+	return hook.Command
+}
+
+func (hook *Hook) validate() error {
+	if hook.Command == "" {
+		return fmt.Errorf("Hook %s must specify a command", hook.displayName())
+	}
+
+	if len(hook.Events) == 0 {
+		return fmt.Errorf("Hook %s must specify at least one event. Must be one of %s", hook.displayName(), hookEventsList)
+	}
+
+	for _, event := range hook.Events {
+		if !hookEvents.Contains(event) {
+			return fmt.Errorf("Hook %s has unknown event %s. Must be one of %s", hook.displayName(), event, hookEventsList)
+		}
+	}
+
+	return nil
+}
+
+// withDir returns copies of the hooks that run from dir
+func withDir(hooks []*Hook, dir string) []*Hook {
+	copies := []*Hook{}
+
+	for _, hook := range hooks {
+		copy := *hook
+		copy.dir = dir
+		copies = append(copies, &copy)
+	}
+
+	return copies
+}
+
+// hookContext holds the information exposed to hooks as environment variables
+type hookContext struct {
+	Environment string
+	AppName     string
+	AppID       string
+	AppURL      string
+	Err         error
+}
+
+func newHookContext(envName string, appName string, app *godo.App) *hookContext {
+	ctx := &hookContext{
+		Environment: envName,
+		AppName:     appName,
+	}
+
+	if app != nil {
+		ctx.AppID = app.ID
+		ctx.AppURL = app.LiveURL
+	}
+
+	return ctx
+}
+
+func (ctx *hookContext) env(event string) []string {
+	vars := []string{
+		fmt.Sprintf("APPFILE_HOOK_EVENT=%s", event),
+		fmt.Sprintf("APPFILE_ENVIRONMENT=%s", ctx.Environment),
+		fmt.Sprintf("APPFILE_APP_NAME=%s", ctx.AppName),
+		fmt.Sprintf("APPFILE_APP_ID=%s", ctx.AppID),
+		fmt.Sprintf("APPFILE_APP_URL=%s", ctx.AppURL),
+	}
+
+	if ctx.Err != nil {
+		vars = append(vars, fmt.Sprintf("APPFILE_ERROR=%s", ctx.Err))
+	}
+
+	return vars
+}
+
+// logger returns the logger of the hooks run for the event
+func (ctx *hookContext) logger(event string) *log.Logger {
+	return log.With(log.Fields{
+		log.FieldComponent:   "hooks",
+		log.FieldEnvironment: ctx.Environment,
+		log.FieldApp:         ctx.AppName,
+		log.FieldOperation:   event,
+	})
+}
+
+// hasHooks returns whether any of the hooks is registered for the event
+func hasHooks(hooks []*Hook, event string) bool {
+	for _, hook := range hooks {
+		if hook.hasEvent(event) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// runHooks runs every hook registered for the event, stopping at the first failure
+func runHooks(ctx context.Context, hooks []*Hook, event string, hookCtx *hookContext) error {
+	for _, hook := range hooks {
+		if !hook.hasEvent(event) {
+			continue
+		}
+
+		logger := hookCtx.logger(event)
+		logger.Debugf("Running hook %s", hook.displayName())
+
+		cmd := exec.CommandContext(ctx, hook.Command, hook.Args...)
+		cmd.Dir = hook.dir
+		cmd.Env = append(os.Environ(), hookCtx.env(event)...)
+
+		output, err := cmd.CombinedOutput()
+		logHookOutput(logger, hook, string(output))
+
+		if err != nil {
+			return errors.Wrapf(err, "%s hook %s failed for app %s", event, hook.displayName(), hookCtx.AppName)
+		}
+	}
+
+	return nil
+}
+
+func logHookOutput(logger *log.Logger, hook *Hook, output string) {
+	for _, line := range strings.Split(strings.TrimRight(output, "\n"), "\n") {
+		if line == "" {
+			continue
+		}
+
+		if hook.ShowLogs {
+			logger.Infof("[%s] %s", hook.displayName(), line)
+		} else {
+			logger.Debugf("[%s] %s", hook.displayName(), line)
+		}
+	}
+}
+
+// runFailureHooks runs the onfailure hooks, logging any error since the
+// original failure is the one reported
+func runFailureHooks(ctx context.Context, hooks []*Hook, hookCtx *hookContext, cause error) {
+	hookCtx.Err = cause
+	if err := runHooks(ctx, hooks, HookEventOnFailure, hookCtx); err != nil {
+		hookCtx.logger(HookEventOnFailure).Errorf("%s", err)
+	}
+}
@@ -0,0 +1,81 @@
+package apps
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/digitalocean/godo"
+	"github.com/stretchr/testify/suite"
+)
+
+type HooksSuite struct {
+	suite.Suite
+}
+
+func (suite *HooksSuite) TestValidateUnknownEvent() {
+	hook := &Hook{
+		Name:    "migrate",
+		Command: "rails",
+		Events:  []string{"presync", "predeploy"},
+	}
+
+	suite.EqualError(
+		hook.validate(),
+		"Hook migrate has unknown event predeploy. Must be one of [presync postsync predestroy postdestroy onfailure]",
+	)
+}
+
+func (suite *HooksSuite) TestValidateMissingCommand() {
+	hook := &Hook{
+		Name:   "notify",
+		Events: []string{"postsync"},
+	}
+
+	suite.EqualError(hook.validate(), "Hook notify must specify a command")
+}
+
+func (suite *HooksSuite) TestValidateMissingEvents() {
+	hook := &Hook{
+		Name:    "migrate",
+		Command: "rails",
+	}
+
+	suite.EqualError(
+		hook.validate(),
+		"Hook migrate must specify at least one event. Must be one of [presync postsync predestroy postdestroy onfailure]",
+	)
+}
+
+func (suite *HooksSuite) TestHookContextEnv() {
+	ctx := newHookContext("review", "sample", &godo.App{
+		ID:      "1234",
+		LiveURL: "https://sample.ondigitalocean.app",
+	})
+	ctx.Err = errors.New("boom")
+
+	suite.Equal([]string{
+		"APPFILE_HOOK_EVENT=onfailure",
+		"APPFILE_ENVIRONMENT=review",
+		"APPFILE_APP_NAME=sample",
+		"APPFILE_APP_ID=1234",
+		"APPFILE_APP_URL=https://sample.ondigitalocean.app",
+		"APPFILE_ERROR=boom",
+	}, ctx.env(HookEventOnFailure))
+}
+
+func (suite *HooksSuite) TestRunHooksSkipsOtherEvents() {
+	hooks := []*Hook{
+		{
+			Command: "command-that-does-not-exist",
+			Events:  []string{HookEventPostSync},
+		},
+	}
+
+	suite.NoError(runHooks(context.Background(), hooks, HookEventPreSync, newHookContext("review", "sample", nil)))
+	suite.Error(runHooks(context.Background(), hooks, HookEventPostSync, newHookContext("review", "sample", nil)))
+}
+
+func TestHooksSuite(t *testing.T) {
+	suite.Run(t, &HooksSuite{})
+}
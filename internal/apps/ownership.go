@@ -0,0 +1,79 @@
+package apps
+
+import (
+	"path/filepath"
+
+	"github.com/digitalocean/godo"
+)
+
+// ManagedEnvironmentKey is the reserved app-level env var appfile uses to mark
+// the apps it manages and the environment they belong to
+const ManagedEnvironmentKey = "APPFILE_MANAGED_ENVIRONMENT"
+
+// ManagedProjectKey is the reserved app-level env var recording the project of
+// the appfile managing the app, so that appfiles sharing environment names
+// don't manage each other's apps
+const ManagedProjectKey = "APPFILE_MANAGED_PROJECT"
+
+// Owner identifies the appfile project and environment managing an app
+type Owner struct {
+	Project     string
+	Environment string
+}
+
+// SetOwner records owner in the ownership markers of the spec, replacing any
+// previous markers
+func (spec *AppSpec) SetOwner(owner Owner) {
+	spec.owner = owner
+	spec.setMarker(ManagedProjectKey, owner.Project)
+	spec.setMarker(ManagedEnvironmentKey, owner.Environment)
+}
+
+func (spec *AppSpec) setMarker(key string, value string) {
+	for _, envVar := range spec.Envs {
+		if envVar.Key == key {
+			envVar.Value = value
+			return
+		}
+	}
+
+	spec.Envs = append(spec.Envs, &godo.AppVariableDefinition{
+		Key:   key,
+		Value: value,
+		Scope: "RUN_TIME",
+		Type:  "GENERAL",
+	})
+}
+
+// AppOwner returns the owner recorded in the spec and whether the spec carries
+// the ownership markers
+func AppOwner(spec *godo.AppSpec) (Owner, bool) {
+	owner := Owner{}
+	if spec == nil {
+		return owner, false
+	}
+
+	managed := false
+	for _, envVar := range spec.Envs {
+		switch envVar.Key {
+		case ManagedEnvironmentKey:
+			owner.Environment = envVar.Value
+			managed = true
+		case ManagedProjectKey:
+			owner.Project = envVar.Value
+		}
+	}
+
+	return owner, managed
+}
+
+// defaultProject returns the project of the appfile at file when it doesn't
+// declare one: the name of the directory holding it
+func defaultProject(file string) string {
+	path, err := filepath.Abs(file)
+	if err != nil {
+		path = file
+	}
+
+	return filepath.Base(filepath.Dir(path))
+}
@@ -0,0 +1,201 @@
+package apps
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/digitalocean/godo"
+	"github.com/renehernandez/appfile/internal/auth"
+	"github.com/renehernandez/appfile/internal/do/fake"
+	"github.com/stretchr/testify/suite"
+)
+
+type OwnershipSuite struct {
+	suite.Suite
+}
+
+func (suite *OwnershipSuite) TestUnmarkedSpecIsNotManaged() {
+	spec := validSpec()
+
+	_, managed := AppOwner(spec.AppSpec)
+
+	suite.False(managed)
+}
+
+func (suite *OwnershipSuite) TestSetOwnerReplacesMarkers() {
+	spec := validSpec()
+
+	spec.SetOwner(Owner{Project: "shop", Environment: "review"})
+	spec.SetOwner(Owner{Project: "shop", Environment: "production"})
+
+	owner, managed := AppOwner(spec.AppSpec)
+
+	suite.True(managed)
+	suite.Equal(Owner{Project: "shop", Environment: "production"}, owner)
+	suite.Len(spec.Envs, 2)
+}
+
+func (suite *OwnershipSuite) TestAppsToPruneOnlyIncludesUndeclaredAppsOfEnvironment() {
+	declared := validSpec()
+	declared.SetOwner(Owner{Project: "shop", Environment: "review"})
+
+	appfile := &Appfile{
+		AppSpecs: []*AppSpec{declared},
+		State: &StateData{
+			Environment: EnvMetadata{Name: "review"},
+		},
+		Project: "shop",
+		owners:  []Owner{{Project: "shop", Environment: "review"}},
+	}
+
+	remoteApps := map[string]*godo.App{
+		declared.Name:    {Spec: declared.AppSpec},
+		"old-review":     {Spec: managedSpec("old-review", "review")},
+		"old-prod":       {Spec: managedSpec("old-prod", "production")},
+		"blog-review":    {Spec: ownedSpec("blog-review", Owner{Project: "blog", Environment: "review"})},
+		"not-appfile":    {Spec: &godo.AppSpec{Name: "not-appfile"}},
+		"another-review": {Spec: managedSpec("another-review", "review")},
+	}
+
+	pruneList := appfile.appsToPrune(remoteApps)
+
+	suite.Len(pruneList, 2)
+	suite.Equal("another-review", pruneList[0].Spec.Name)
+	suite.Equal("old-review", pruneList[1].Spec.Name)
+}
+
+func (suite *OwnershipSuite) TestCheckOwnership() {
+	appfile := &Appfile{
+		State: &StateData{
+			Environment: EnvMetadata{Name: "review"},
+		},
+		Project: "shop",
+	}
+
+	suite.NoError(appfile.checkOwnership(&godo.App{Spec: managedSpec("sample", "review")}))
+	suite.EqualError(
+		appfile.checkOwnership(&godo.App{Spec: &godo.AppSpec{Name: "sample"}}),
+		"App sample was not created by appfile. Use --adopt to manage it anyway",
+	)
+	suite.EqualError(
+		appfile.checkOwnership(&godo.App{Spec: managedSpec("sample", "production")}),
+		"App sample is managed by environment production. Use --adopt to manage it from environment review",
+	)
+	suite.EqualError(
+		appfile.checkOwnership(&godo.App{Spec: ownedSpec("sample", Owner{Project: "blog", Environment: "review"})}),
+		"App sample is managed by project blog. Use --adopt to manage it from project shop",
+	)
+}
+
+func (suite *OwnershipSuite) TestAppfilesSharingEnvironmentDontPruneEachOtherApps() {
+	backend := fake.NewBackend()
+	shop := suite.projectAppfile(backend, "shop", "shop-web", "shop-worker")
+	blog := suite.projectAppfile(backend, "blog", "blog-web", "blog-worker")
+
+	_, err := shop.Sync(context.Background(), SyncOptions{})
+	suite.Require().NoError(err)
+	_, err = blog.Sync(context.Background(), SyncOptions{})
+	suite.Require().NoError(err)
+	suite.Len(backend.Apps(), 4)
+
+	shop.AppSpecs = shop.AppSpecs[:1]
+	summary, err := shop.Sync(context.Background(), SyncOptions{Prune: true})
+	suite.Require().NoError(err)
+	suite.Equal(1, summary.Count(ResultDestroyed))
+
+	blog.AppSpecs = blog.AppSpecs[:0]
+	summary, err = blog.Sync(context.Background(), SyncOptions{Prune: true})
+	suite.Require().NoError(err)
+	suite.Equal(2, summary.Count(ResultDestroyed))
+
+	apps := backend.Apps()
+	suite.Require().Len(apps, 1)
+	suite.Equal(shop.AppSpecs[0].Name, apps[0].Spec.Name)
+}
+
+func (suite *OwnershipSuite) TestSyncRefusesAppsOfAnotherProject() {
+	backend := fake.NewBackend()
+
+	_, err := suite.projectAppfile(backend, "shop", "web").Sync(context.Background(), SyncOptions{})
+	suite.Require().NoError(err)
+
+	_, err = suite.projectAppfile(backend, "blog", "web").Sync(context.Background(), SyncOptions{})
+	suite.Require().Error(err)
+	suite.Contains(err.Error(), "App web is managed by project shop")
+}
+
+func (suite *OwnershipSuite) TestNestedAppfilesOwnTheirApps() {
+	backend := fake.NewBackend()
+
+	platform, err := ReadAppfileSpec("../../testdata/nested/appfile.yaml")
+	suite.Require().NoError(err)
+	appfile, err := NewAppfileFromSpec(platform, "review", backend, auth.NewStaticResolver("token"))
+	suite.Require().NoError(err)
+	_, err = appfile.Sync(context.Background(), SyncOptions{})
+	suite.Require().NoError(err)
+
+	owners := map[string]Owner{}
+	for _, app := range backend.Apps() {
+		owners[app.Spec.Name], _ = AppOwner(app.Spec)
+	}
+	suite.Equal(map[string]Owner{
+		"team-a-review":           {Project: "team-a", Environment: "review"},
+		"team-b-staging-platform": {Project: "team-b", Environment: "staging"},
+	}, owners)
+
+	team, err := ReadAppfileSpec("../../testdata/nested/team-a/appfile.yaml")
+	suite.Require().NoError(err)
+	appfile, err = NewAppfileFromSpec(team, "review", backend, auth.NewStaticResolver("token"))
+	suite.Require().NoError(err)
+	summary, err := appfile.Sync(context.Background(), SyncOptions{Prune: true})
+
+	suite.Require().NoError(err)
+	suite.Equal(1, summary.Count(ResultUnchanged))
+	suite.Equal(0, summary.Count(ResultDestroyed))
+	suite.Len(backend.Apps(), 2)
+}
+
+// projectAppfile writes and loads an appfile of the project declaring an app
+// with each of the names
+func (suite *OwnershipSuite) projectAppfile(backend *fake.Backend, project string, names ...string) *Appfile {
+	dir := suite.T().TempDir()
+
+	content := fmt.Sprintf("project: %s\nenvironments:\n  review: {}\nspecs:\n", project)
+	for _, name := range names {
+		suite.Require().NoError(ioutil.WriteFile(filepath.Join(dir, name+".yaml"), []byte("name: "+name+"\n"), 0644))
+		content += fmt.Sprintf("- ./%s.yaml\n", name)
+	}
+	file := filepath.Join(dir, "appfile.yaml")
+	suite.Require().NoError(ioutil.WriteFile(file, []byte(content), 0644))
+
+	spec, err := ReadAppfileSpec(file)
+	suite.Require().NoError(err)
+
+	appfile, err := NewAppfileFromSpec(spec, "review", backend, auth.NewStaticResolver("token"))
+	suite.Require().NoError(err)
+
+	return appfile
+}
+
+func managedSpec(name string, envName string) *godo.AppSpec {
+	spec := NewAppSpec()
+	spec.Name = name
+	spec.SetOwner(Owner{Project: "shop", Environment: envName})
+
+	return spec.AppSpec
+}
+
+func ownedSpec(name string, owner Owner) *godo.AppSpec {
+	spec := NewAppSpec()
+	spec.Name = name
+	spec.SetOwner(owner)
+
+	return spec.AppSpec
+}
+
+func TestOwnershipSuite(t *testing.T) {
+	suite.Run(t, &OwnershipSuite{})
+}
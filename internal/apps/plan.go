@@ -0,0 +1,260 @@
+package apps
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"time"
+
+	"github.com/digitalocean/godo"
+	"github.com/pkg/errors"
+	apperrors "github.com/renehernandez/appfile/internal/errors"
+	"github.com/renehernandez/appfile/internal/version"
+)
+
+// Actions recorded in a plan for every app
+const (
+	ActionCreate    = "create"
+	ActionUpdate    = "update"
+	ActionUnchanged = "unchanged"
+	ActionDestroy   = "destroy"
+)
+
+// PlanOptions configures the changes recorded by Plan
+type PlanOptions struct {
+	// Prune records the destruction of the apps managed by the environment that are no longer declared
+	Prune bool
+	// Adopt allows updating apps that were not created by the environment
+	Adopt bool
+	// Force updates the apps whose spec didn't change
+	Force bool
+	// OnDrift is the policy on the apps changed in DigitalOcean since they were
+	// last synced: DriftFail, DriftWarn or DriftOverwrite. Defaults to DriftFail
+	OnDrift string
+}
+
+// Plan records the changes to apply to DigitalOcean, so that they can be
+// reviewed and applied later exactly as planned
+type Plan struct {
+	// Version is the version of appfile that made the plan
+	Version     string        `json:"version"`
+	Environment string        `json:"environment"`
+	CreatedAt   time.Time     `json:"created_at"`
+	Apps        []*PlannedApp `json:"apps"`
+}
+
+// PlannedApp is the change planned for an app. Apps to update or destroy
+// record the ID and the fingerprint of the remote spec when the plan was made
+type PlannedApp struct {
+	Name              string        `json:"name"`
+	Action            string        `json:"action"`
+	Spec              *godo.AppSpec `json:"spec,omitempty"`
+	RemoteID          string        `json:"remote_id,omitempty"`
+	RemoteFingerprint string        `json:"remote_fingerprint,omitempty"`
+}
+
+// ReadPlan reads the plan from file
+func ReadPlan(file string) (*Plan, error) {
+	content, err := ioutil.ReadFile(file)
+	if err != nil {
+		return &Plan{}, apperrors.Wrap(apperrors.KindConfig, errors.Wrapf(err, "Failed to read plan from %s", file))
+	}
+
+	var plan Plan
+	if err := json.Unmarshal(content, &plan); err != nil {
+		return &Plan{}, apperrors.Wrap(apperrors.KindConfig, errors.Wrapf(err, "Failed to parse plan from %s", file))
+	}
+
+	return &plan, nil
+}
+
+// Write writes the plan to file
+func (plan *Plan) Write(file string) error {
+	content, err := json.MarshalIndent(plan, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "Failed to serialize plan")
+	}
+
+	if err := ioutil.WriteFile(file, append(content, '\n'), 0600); err != nil {
+		return apperrors.Wrap(apperrors.KindConfig, errors.Wrapf(err, "Failed to write plan to %s", file))
+	}
+
+	return nil
+}
+
+// Fingerprint returns the fingerprint of an app spec, which changes with any
+// change to the spec
+func Fingerprint(spec *godo.AppSpec) (string, error) {
+	content, err := json.Marshal(spec)
+	if err != nil {
+		return "", errors.Wrapf(err, "Failed to compute fingerprint of app %s", spec.Name)
+	}
+
+	return fmt.Sprintf("sha256:%x", sha256.Sum256(content)), nil
+}
+
+// Plan records the changes that Sync would apply with the same options
+func (appfile *Appfile) Plan(ctx context.Context, opts PlanOptions) (*Plan, error) {
+	remoteApps, err := appfile.readAppsFromRemote(ctx)
+	if err != nil {
+		return &Plan{}, err
+	}
+
+	if err := appfile.checkDrift(remoteApps, opts.OnDrift, false); err != nil {
+		return &Plan{}, err
+	}
+
+	plan := &Plan{
+		Version:     version.Version,
+		Environment: appfile.State.Environment.Name,
+		CreatedAt:   time.Now().UTC(),
+		Apps:        []*PlannedApp{},
+	}
+
+	for _, appSpec := range appfile.AppSpecs {
+		planned := &PlannedApp{
+			Name:   appSpec.Name,
+			Action: ActionCreate,
+			Spec:   appSpec.AppSpec,
+		}
+
+		if remoteApp, ok := remoteApps[appSpec.Name]; ok {
+			if !opts.Adopt {
+				if err := appfile.checkOwnership(remoteApp); err != nil {
+					return &Plan{}, err
+				}
+			}
+
+			planned.Action = ActionUpdate
+			if err := planned.recordRemote(remoteApp); err != nil {
+				return &Plan{}, err
+			}
+
+			unchanged, err := appfile.unchanged(ctx, appSpec.AppSpec, remoteApp, opts.Force)
+			if err != nil {
+				return &Plan{}, err
+			}
+			if unchanged {
+				planned.Action = ActionUnchanged
+			}
+		}
+
+		plan.Apps = append(plan.Apps, planned)
+	}
+
+	if opts.Prune {
+		for _, app := range appfile.appsToPrune(remoteApps) {
+			planned := &PlannedApp{
+				Name:   app.Spec.Name,
+				Action: ActionDestroy,
+			}
+			if err := planned.recordRemote(app); err != nil {
+				return &Plan{}, err
+			}
+
+			plan.Apps = append(plan.Apps, planned)
+		}
+	}
+
+	return plan, nil
+}
+
+func (planned *PlannedApp) recordRemote(remoteApp *godo.App) error {
+	fingerprint, err := Fingerprint(remoteApp.Spec)
+	if err != nil {
+		return err
+	}
+
+	planned.RemoteID = remoteApp.ID
+	planned.RemoteFingerprint = fingerprint
+
+	return nil
+}
+
+// Apply executes the plan. It refuses to run if the plan was made by a
+// different version of appfile or for another environment, or if any of the
+// remote apps changed since the plan was made
+func (appfile *Appfile) Apply(ctx context.Context, plan *Plan) (*Summary, error) {
+	summary := &Summary{}
+
+	if plan.Version != version.Version {
+		return summary, apperrors.New(apperrors.KindConfig, "Plan was made with appfile %s and cannot be applied with appfile %s", plan.Version, version.Version)
+	}
+
+	if plan.Environment != appfile.State.Environment.Name {
+		return summary, apperrors.New(apperrors.KindConfig, "Plan was made for environment %s and cannot be applied to environment %s", plan.Environment, appfile.State.Environment.Name)
+	}
+
+	declared := map[string]bool{}
+	for _, appSpec := range appfile.AppSpecs {
+		declared[appSpec.Name] = true
+	}
+
+	remoteApps, err := appfile.readAppsFromRemote(ctx)
+	if err != nil {
+		return summary, err
+	}
+
+	specs := []*godo.AppSpec{}
+	pruneList := []*godo.App{}
+	changed := []string{}
+
+	for _, planned := range plan.Apps {
+		remoteApp, ok := remoteApps[planned.Name]
+
+		switch planned.Action {
+		case ActionCreate, ActionUpdate:
+			if !declared[planned.Name] {
+				return summary, apperrors.New(apperrors.KindConfig, "App %s of the plan is not declared in environment %s of the appfile", planned.Name, plan.Environment)
+			}
+			if planned.Spec == nil || planned.Spec.Name != planned.Name {
+				return summary, apperrors.New(apperrors.KindConfig, "Plan has an invalid spec for app %s", planned.Name)
+			}
+			specs = append(specs, planned.Spec)
+		case ActionUnchanged:
+			summary.add(planned.Name, ResultUnchanged, nil)
+		case ActionDestroy:
+			if ok {
+				pruneList = append(pruneList, remoteApp)
+			}
+		default:
+			return summary, apperrors.New(apperrors.KindConfig, "Plan has unknown action %s for app %s", planned.Action, planned.Name)
+		}
+
+		unchanged, err := planned.remoteUnchanged(remoteApp)
+		if err != nil {
+			return summary, err
+		}
+		if !unchanged {
+			changed = append(changed, planned.Name)
+		}
+	}
+
+	if len(changed) > 0 {
+		return summary, apperrors.New(apperrors.KindDeployment, "Apps changed in DigitalOcean since the plan was made: %s. Make a new plan", strings.Join(changed, ", "))
+	}
+
+	return summary, appfile.syncApps(ctx, specs, remoteApps, pruneList, SyncOptions{Force: true}, summary)
+}
+
+// remoteUnchanged reports whether the remote app, nil if it doesn't exist, is
+// still the one recorded in the plan
+func (planned *PlannedApp) remoteUnchanged(remoteApp *godo.App) (bool, error) {
+	if remoteApp == nil {
+		return planned.RemoteID == "", nil
+	}
+
+	if remoteApp.ID != planned.RemoteID {
+		return false, nil
+	}
+
+	fingerprint, err := Fingerprint(remoteApp.Spec)
+	if err != nil {
+		return false, err
+	}
+
+	return fingerprint == planned.RemoteFingerprint, nil
+}
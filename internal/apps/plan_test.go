@@ -0,0 +1,137 @@
+package apps
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/digitalocean/godo"
+	"github.com/renehernandez/appfile/internal/auth"
+	"github.com/renehernandez/appfile/internal/do/fake"
+	apperrors "github.com/renehernandez/appfile/internal/errors"
+	"github.com/renehernandez/appfile/internal/version"
+	"github.com/stretchr/testify/suite"
+)
+
+type PlanSuite struct {
+	suite.Suite
+
+	backend *fake.Backend
+	appfile *Appfile
+}
+
+func (suite *PlanSuite) SetupTest() {
+	suite.backend = fake.NewBackend()
+
+	spec, err := ReadAppfileSpec("../../testdata/nested/appfile.yaml")
+	suite.Require().NoError(err)
+
+	suite.appfile, err = NewAppfileFromSpec(spec, "review", suite.backend, auth.NewStaticResolver("token"))
+	suite.Require().NoError(err)
+}
+
+func (suite *PlanSuite) TestApplyExecutesThePlan() {
+	plan := suite.plan(PlanOptions{})
+	suite.Empty(suite.backend.Apps())
+
+	summary, err := suite.appfile.Apply(context.Background(), plan)
+
+	suite.Require().NoError(err)
+	suite.Equal(2, summary.Count(ResultCreated))
+	suite.Len(suite.backend.Apps(), 2)
+
+	plan = suite.plan(PlanOptions{})
+	for _, planned := range plan.Apps {
+		suite.Equal(ActionUnchanged, planned.Action)
+		suite.NotEmpty(planned.RemoteID)
+		suite.Contains(planned.RemoteFingerprint, "sha256:")
+	}
+}
+
+func (suite *PlanSuite) TestPlanRoundTripsThroughFile() {
+	file := filepath.Join(suite.T().TempDir(), "plan.json")
+	suite.Require().NoError(suite.plan(PlanOptions{}).Write(file))
+
+	info, err := os.Stat(file)
+	suite.Require().NoError(err)
+	suite.Equal(os.FileMode(0600), info.Mode().Perm())
+
+	plan, err := ReadPlan(file)
+	suite.Require().NoError(err)
+
+	suite.Equal("review", plan.Environment)
+	suite.Equal(version.Version, plan.Version)
+	suite.Len(plan.Apps, 2)
+	suite.Equal("team-a-review", plan.Apps[0].Spec.Name)
+}
+
+func (suite *PlanSuite) TestApplyRefusesChangedRemoteApps() {
+	_, err := suite.appfile.Sync(context.Background(), SyncOptions{})
+	suite.Require().NoError(err)
+	plan := suite.plan(PlanOptions{})
+
+	remote := suite.backend.Apps()[0]
+	changed := *remote.Spec
+	changed.Region = "ams"
+	_, err = suite.backend.AppService("token").Update(context.Background(), &godo.App{Spec: &changed}, remote)
+	suite.Require().NoError(err)
+
+	_, err = suite.appfile.Apply(context.Background(), plan)
+
+	suite.EqualError(err, "Apps changed in DigitalOcean since the plan was made: team-a-review. Make a new plan")
+	suite.Equal(apperrors.KindDeployment, apperrors.KindOf(err))
+}
+
+func (suite *PlanSuite) TestApplyRefusesAppsCreatedAfterThePlan() {
+	plan := suite.plan(PlanOptions{})
+
+	stale := &AppSpec{AppSpec: &godo.AppSpec{Name: "team-a-review"}}
+	stale.SetOwner(Owner{Project: "nested", Environment: "review"})
+	_, err := suite.backend.AddApp(stale.AppSpec)
+	suite.Require().NoError(err)
+
+	_, err = suite.appfile.Apply(context.Background(), plan)
+
+	suite.EqualError(err, "Apps changed in DigitalOcean since the plan was made: team-a-review. Make a new plan")
+	suite.Len(suite.backend.Apps(), 1)
+}
+
+func (suite *PlanSuite) TestApplyRefusesPlansOfOtherVersions() {
+	plan := suite.plan(PlanOptions{})
+	plan.Version = "0.0.0-other"
+
+	_, err := suite.appfile.Apply(context.Background(), plan)
+
+	suite.EqualError(err, "Plan was made with appfile 0.0.0-other and cannot be applied with appfile "+version.Version)
+	suite.Empty(suite.backend.Apps())
+}
+
+func (suite *PlanSuite) TestApplyPrunesPlannedApps() {
+	stale := &AppSpec{AppSpec: &godo.AppSpec{Name: "stale-review"}}
+	stale.SetOwner(Owner{Project: "nested", Environment: "review"})
+	_, err := suite.backend.AddApp(stale.AppSpec)
+	suite.Require().NoError(err)
+
+	plan := suite.plan(PlanOptions{Prune: true})
+	suite.Require().Len(plan.Apps, 3)
+	suite.Equal(ActionDestroy, plan.Apps[2].Action)
+	suite.Nil(plan.Apps[2].Spec)
+
+	summary, err := suite.appfile.Apply(context.Background(), plan)
+
+	suite.Require().NoError(err)
+	suite.Equal(1, summary.Count(ResultDestroyed))
+	suite.ElementsMatch([]string{"team-a-review", "team-b-staging-platform"}, appNames(suite.backend.Apps()))
+}
+
+func (suite *PlanSuite) plan(opts PlanOptions) *Plan {
+	plan, err := suite.appfile.Plan(context.Background(), opts)
+	suite.Require().NoError(err)
+
+	return plan
+}
+
+func TestPlanSuite(t *testing.T) {
+	suite.Run(t, &PlanSuite{})
+}
@@ -0,0 +1,72 @@
+package apps
+
+const (
+	ResultCreated   = "created"
+	ResultUpdated   = "updated"
+	ResultUnchanged = "unchanged"
+	ResultDestroyed = "destroyed"
+	ResultFailed    = "failed"
+	ResultSkipped   = "skipped"
+)
+
+// AppResult is the outcome of the operation on an app
+type AppResult struct {
+	Name   string
+	Result string
+	Err    error
+}
+
+// Summary collects the outcome of the operations on the apps, in the order they finished
+type Summary struct {
+	Results []*AppResult
+	// Interrupted is set when the operations stopped before processing every app
+	Interrupted bool
+	// Aborted is set when the sync was aborted when asked to approve an app
+	Aborted bool
+}
+
+func (summary *Summary) add(name string, result string, err error) {
+	for _, appResult := range summary.Results {
+		if appResult.Name == name {
+			appResult.Result = result
+			appResult.Err = err
+			return
+		}
+	}
+
+	summary.Results = append(summary.Results, &AppResult{
+		Name:   name,
+		Result: result,
+		Err:    err,
+	})
+}
+
+// skip records the apps that were not processed because of an interruption
+func (summary *Summary) skip(names ...string) {
+	summary.Interrupted = true
+
+	for _, name := range names {
+		summary.add(name, ResultSkipped, nil)
+	}
+}
+
+// abort records the apps that were not processed because the sync was aborted
+func (summary *Summary) abort(names ...string) {
+	summary.Aborted = true
+
+	for _, name := range names {
+		summary.add(name, ResultSkipped, nil)
+	}
+}
+
+// Count returns the number of apps with the given result
+func (summary *Summary) Count(result string) int {
+	count := 0
+	for _, appResult := range summary.Results {
+		if appResult.Result == result {
+			count++
+		}
+	}
+
+	return count
+}
@@ -0,0 +1,189 @@
+package apps
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+
+	"github.com/digitalocean/godo"
+)
+
+// Kinds of the changes reported by a three-way diff
+const (
+	// ChangeLocal is a field changed in the appfile since the app was last synced
+	ChangeLocal = "local"
+	// ChangeDrift is a field changed in DigitalOcean since the app was last synced
+	ChangeDrift = "drift"
+	// ChangeConflict is a field changed both in the appfile and in DigitalOcean
+	ChangeConflict = "conflict"
+)
+
+// listKeys holds the fields identifying the items of the lists in the paths of
+// the changes, tried in order. Items without any of them are identified by index
+var listKeys = []string{"name", "key", "domain"}
+
+// ThreeWayDiff compares the spec of an app as it was last synced with the spec
+// running in DigitalOcean and the spec declared in the appfile
+type ThreeWayDiff struct {
+	Name string `json:"name"`
+	// Recorded is unset when the app was never synced with the applied state.
+	// The live spec is then used as last synced spec, so every difference is a
+	// local change
+	Recorded bool           `json:"recorded"`
+	Changes  []*FieldChange `json:"changes"`
+}
+
+// FieldChange is a field whose value differs between the live and desired specs.
+// Values missing in a spec are nil
+type FieldChange struct {
+	Path        string      `json:"path"`
+	Kind        string      `json:"kind"`
+	LastApplied interface{} `json:"last_applied,omitempty"`
+	Live        interface{} `json:"live,omitempty"`
+	Desired     interface{} `json:"desired,omitempty"`
+}
+
+// ThreeWayDiff classifies the differences between the remote and the declared
+// apps as local changes, remote drift or conflicts, using the apps as they were
+// last synced
+func (appfile *Appfile) ThreeWayDiff(ctx context.Context) ([]*ThreeWayDiff, error) {
+	remoteApps, err := appfile.readAppsFromRemote(ctx)
+	if err != nil {
+		return []*ThreeWayDiff{}, err
+	}
+
+	applied := appfile.Applied
+	if applied == nil {
+		applied = NewAppliedState()
+	}
+
+	diffs := []*ThreeWayDiff{}
+	for _, appSpec := range appfile.AppSpecs {
+		var live *godo.AppSpec
+		if remoteApp, ok := remoteApps[appSpec.Name]; ok {
+			live = remoteApp.Spec
+		}
+
+		base := live
+		record, recorded := applied.Lookup(appfile.State.Environment.Name, appSpec.Name)
+		if recorded {
+			base = record.Spec
+		}
+
+		changes, err := threeWayChanges(base, live, appSpec.AppSpec)
+		if err != nil {
+			return []*ThreeWayDiff{}, err
+		}
+
+		diffs = append(diffs, &ThreeWayDiff{
+			Name:     appSpec.Name,
+			Recorded: recorded,
+			Changes:  changes,
+		})
+	}
+
+	return diffs, nil
+}
+
+// threeWayChanges returns the changes to the fields whose live and desired
+// values differ, sorted by path
+func threeWayChanges(base *godo.AppSpec, live *godo.AppSpec, desired *godo.AppSpec) ([]*FieldChange, error) {
+	fields := []map[string]interface{}{}
+
+	baseCopy, err := copySpec(base)
+	if err != nil {
+		return []*FieldChange{}, err
+	}
+	liveCopy, err := copySpec(live)
+	if err != nil {
+		return []*FieldChange{}, err
+	}
+	desiredCopy, err := copySpec(desired)
+	if err != nil {
+		return []*FieldChange{}, err
+	}
+
+	maskEncryptedSecrets(desiredCopy, liveCopy)
+	maskEncryptedSecrets(baseCopy, baseCopy)
+
+	for _, spec := range []*godo.AppSpec{baseCopy, liveCopy, desiredCopy} {
+		normalized, err := normalizeSpec(spec)
+		if err != nil {
+			return []*FieldChange{}, err
+		}
+
+		flattened := map[string]interface{}{}
+		flattenValue(normalized, "", flattened)
+		fields = append(fields, flattened)
+	}
+	baseFields, liveFields, desiredFields := fields[0], fields[1], fields[2]
+
+	paths := map[string]bool{}
+	for _, flattened := range fields {
+		for path := range flattened {
+			paths[path] = true
+		}
+	}
+
+	changes := []*FieldChange{}
+	for path := range paths {
+		baseValue, liveValue, desiredValue := baseFields[path], liveFields[path], desiredFields[path]
+		if reflect.DeepEqual(liveValue, desiredValue) {
+			continue
+		}
+
+		kind := ChangeConflict
+		switch {
+		case reflect.DeepEqual(baseValue, liveValue):
+			kind = ChangeLocal
+		case reflect.DeepEqual(baseValue, desiredValue):
+			kind = ChangeDrift
+		}
+
+		changes = append(changes, &FieldChange{
+			Path:        path,
+			Kind:        kind,
+			LastApplied: baseValue,
+			Live:        liveValue,
+			Desired:     desiredValue,
+		})
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Path < changes[j].Path
+	})
+
+	return changes, nil
+}
+
+// flattenValue records the scalar values of a normalized spec by path, such as
+// services[web].envs[PORT].value
+func flattenValue(value interface{}, path string, fields map[string]interface{}) {
+	switch typed := value.(type) {
+	case map[string]interface{}:
+		for key, field := range typed {
+			fieldPath := key
+			if path != "" {
+				fieldPath = path + "." + key
+			}
+			flattenValue(field, fieldPath, fields)
+		}
+	case []interface{}:
+		for i, item := range typed {
+			flattenValue(item, fmt.Sprintf("%s[%s]", path, listItemKey(item, i)), fields)
+		}
+	default:
+		fields[path] = value
+	}
+}
+
+func listItemKey(item interface{}, index int) string {
+	for _, key := range listKeys {
+		if value := sortValue(item, key); value != "" {
+			return value
+		}
+	}
+
+	return fmt.Sprint(index)
+}
@@ -0,0 +1,111 @@
+package apps
+
+import (
+	"context"
+	"testing"
+
+	"github.com/digitalocean/godo"
+	"github.com/renehernandez/appfile/internal/auth"
+	"github.com/renehernandez/appfile/internal/do/fake"
+	"github.com/stretchr/testify/suite"
+)
+
+type ThreeWaySuite struct {
+	suite.Suite
+}
+
+func (suite *ThreeWaySuite) TestChangesAreClassified() {
+	base := &godo.AppSpec{
+		Name:   "web",
+		Region: "nyc",
+		Services: []*godo.AppServiceSpec{
+			{Name: "api", InstanceCount: 1, HTTPPort: 8080, Envs: []*godo.AppVariableDefinition{{Key: "PORT", Value: "8080"}}},
+		},
+	}
+	live := &godo.AppSpec{
+		Name:   "web",
+		Region: "nyc",
+		Services: []*godo.AppServiceSpec{
+			{Name: "api", InstanceCount: 2, HTTPPort: 8081, Envs: []*godo.AppVariableDefinition{{Key: "PORT", Value: "8080"}}},
+		},
+	}
+	desired := &godo.AppSpec{
+		Name:   "web",
+		Region: "ams",
+		Services: []*godo.AppServiceSpec{
+			{Name: "api", InstanceCount: 1, HTTPPort: 3000, Envs: []*godo.AppVariableDefinition{{Key: "PORT", Value: "3000"}}},
+		},
+	}
+
+	changes, err := threeWayChanges(base, live, desired)
+	suite.Require().NoError(err)
+
+	kinds := map[string]string{}
+	for _, change := range changes {
+		kinds[change.Path] = change.Kind
+	}
+
+	suite.Equal(map[string]string{
+		"region":                         ChangeLocal,
+		"services[api].envs[PORT].value": ChangeLocal,
+		"services[api].instance_count":   ChangeDrift,
+		"services[api].http_port":        ChangeConflict,
+	}, kinds)
+
+	suite.Equal("services[api].http_port", changes[2].Path)
+	suite.Equal(float64(8080), changes[2].LastApplied)
+	suite.Equal(float64(8081), changes[2].Live)
+	suite.Equal(float64(3000), changes[2].Desired)
+}
+
+func (suite *ThreeWaySuite) TestEncryptedSecretsAreNotCompared() {
+	secret := func(value string) *godo.AppSpec {
+		return &godo.AppSpec{
+			Name: "web",
+			Envs: []*godo.AppVariableDefinition{{Key: "TOKEN", Value: value, Type: godo.AppVariableType_Secret}},
+		}
+	}
+
+	changes, err := threeWayChanges(secret("EV[1:abc]"), secret("EV[1:def]"), secret("plain"))
+	suite.Require().NoError(err)
+	suite.Empty(changes)
+}
+
+func (suite *ThreeWaySuite) TestAppfileThreeWayDiff() {
+	backend := fake.NewBackend()
+	spec, err := ReadAppfileSpec("../../testdata/nested/appfile.yaml")
+	suite.Require().NoError(err)
+
+	appfile, err := NewAppfileFromSpec(spec, "review", backend, auth.NewStaticResolver("token"))
+	suite.Require().NoError(err)
+
+	diffs, err := appfile.ThreeWayDiff(context.Background())
+	suite.Require().NoError(err)
+	suite.Require().Len(diffs, 2)
+	suite.False(diffs[0].Recorded)
+	suite.NotEmpty(diffs[0].Changes)
+	for _, change := range diffs[0].Changes {
+		suite.Equal(ChangeLocal, change.Kind)
+	}
+
+	appfile.Applied = NewAppliedState()
+	_, err = appfile.Sync(context.Background(), SyncOptions{})
+	suite.Require().NoError(err)
+
+	remote := backend.Apps()[0]
+	changed := *remote.Spec
+	changed.Region = "ams"
+	_, err = backend.AppService("token").Update(context.Background(), &godo.App{Spec: &changed}, remote)
+	suite.Require().NoError(err)
+
+	diffs, err = appfile.ThreeWayDiff(context.Background())
+	suite.Require().NoError(err)
+	suite.True(diffs[0].Recorded)
+	suite.Require().Len(diffs[0].Changes, 1)
+	suite.Equal(&FieldChange{Path: "region", Kind: ChangeDrift, Live: "ams"}, diffs[0].Changes[0])
+	suite.Empty(diffs[1].Changes)
+}
+
+func TestThreeWaySuite(t *testing.T) {
+	suite.Run(t, &ThreeWaySuite{})
+}
@@ -0,0 +1,160 @@
+// Package auth reads the DigitalOcean access tokens from files, doctl
+// configuration and credential helper commands
+package auth
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/goccy/go-yaml"
+	"github.com/pkg/errors"
+	"github.com/renehernandez/appfile/internal/log"
+)
+
+// DefaultDoctlContext is the doctl context using the top level access-token
+const DefaultDoctlContext = "default"
+
+// Command is a credential helper printing the access token on its standard output
+type Command struct {
+	Command string   `yaml:"command"`
+	Args    []string `yaml:"args"`
+
+	dir string
+}
+
+func (command *Command) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var name string
+	if err := unmarshal(&name); err == nil {
+		command.Command = name
+		return nil
+	}
+
+	type rawCommand Command
+	var raw rawCommand
+	if err := unmarshal(&raw); err != nil {
+		return err
+	}
+
+	*command = Command(raw)
+	return nil
+}
+
+// SetDir sets the directory the command runs from
+func (command *Command) SetDir(dir string) {
+	command.dir = dir
+}
+
+func (command *Command) String() string {
+	return strings.Join(append([]string{command.Command}, command.Args...), " ")
+}
+
+// Token runs the command and returns its trimmed output. The command can
+// prompt the user, since it shares the standard input and error of appfile
+func (command *Command) Token() (string, error) {
+	if command.Command == "" {
+		return "", fmt.Errorf("Token command must specify a command")
+	}
+
+	log.Debugf("Reading access token from command %s", command.Command)
+
+	var stdout bytes.Buffer
+	cmd := exec.Command(command.Command, command.Args...)
+	cmd.Dir = command.dir
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = &stdout
+	cmd.Stderr = os.Stderr
+
+	if err := cmd.Run(); err != nil {
+		return "", errors.Wrapf(err, "Token command %s failed", command.Command)
+	}
+
+	token := strings.TrimSpace(stdout.String())
+	if token == "" {
+		return "", fmt.Errorf("Token command %s did not print any token", command.Command)
+	}
+
+	return token, nil
+}
+
+// FromFile returns the trimmed content of file
+func FromFile(file string) (string, error) {
+	log.Debugf("Reading access token from file %s", file)
+
+	info, err := os.Stat(file)
+	if err != nil {
+		return "", errors.Wrapf(err, "Failed to read token file %s", file)
+	}
+
+	if info.Mode().Perm()&0077 != 0 {
+		log.Warningf("Token file %s is accessible by other users, consider restricting its permissions to 0600", file)
+	}
+
+	content, err := ioutil.ReadFile(file)
+	if err != nil {
+		return "", errors.Wrapf(err, "Failed to read token file %s", file)
+	}
+
+	token := strings.TrimSpace(string(content))
+	if token == "" {
+		return "", fmt.Errorf("Token file %s is empty", file)
+	}
+
+	return token, nil
+}
+
+// doctlConfig holds the authentication settings of the doctl configuration file
+type doctlConfig struct {
+	AccessToken  string            `yaml:"access-token"`
+	AuthContexts map[string]string `yaml:"auth-contexts"`
+	Context      string            `yaml:"context"`
+}
+
+// DoctlConfigFile returns the default location of the doctl configuration file
+func DoctlConfigFile() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", errors.Wrap(err, "Failed to find the doctl configuration directory")
+	}
+
+	return filepath.Join(dir, "doctl", "config.yaml"), nil
+}
+
+// FromDoctl returns the token of the doctl auth context with the given name.
+// An empty name selects the current context of the configuration
+func FromDoctl(configFile string, contextName string) (string, error) {
+	content, err := ioutil.ReadFile(configFile)
+	if err != nil {
+		return "", errors.Wrapf(err, "Failed to read doctl configuration from %s", configFile)
+	}
+
+	var config doctlConfig
+	if err = yaml.Unmarshal(content, &config); err != nil {
+		return "", errors.Wrapf(err, "Failed to parse doctl configuration from %s", configFile)
+	}
+
+	if contextName == "" {
+		contextName = config.Context
+	}
+
+	if contextName == "" || contextName == DefaultDoctlContext {
+		log.Infof("Using access token of doctl default context from %s", configFile)
+		if config.AccessToken == "" {
+			return "", fmt.Errorf("No access token for the doctl default context in %s", configFile)
+		}
+
+		return config.AccessToken, nil
+	}
+
+	log.Infof("Using access token of doctl context %s from %s", contextName, configFile)
+	token, ok := config.AuthContexts[contextName]
+	if !ok || token == "" {
+		return "", fmt.Errorf("No access token for doctl context %s in %s", contextName, configFile)
+	}
+
+	return token, nil
+}
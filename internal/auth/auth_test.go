@@ -0,0 +1,130 @@
+package auth
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type AuthSuite struct {
+	suite.Suite
+}
+
+func (suite *AuthSuite) TestFromFile() {
+	file := suite.writeFile("token", "  secret-token\n")
+
+	token, err := FromFile(file)
+
+	suite.NoError(err)
+	suite.Equal("secret-token", token)
+}
+
+func (suite *AuthSuite) TestFromEmptyFile() {
+	file := suite.writeFile("token", "\n")
+
+	_, err := FromFile(file)
+
+	suite.EqualError(err, "Token file "+file+" is empty")
+}
+
+func (suite *AuthSuite) TestFromDoctl() {
+	file := suite.writeFile("config.yaml", `access-token: default-token
+auth-contexts:
+  work: work-token
+context: default
+`)
+
+	token, err := FromDoctl(file, "")
+	suite.NoError(err)
+	suite.Equal("default-token", token)
+
+	token, err = FromDoctl(file, "work")
+	suite.NoError(err)
+	suite.Equal("work-token", token)
+
+	_, err = FromDoctl(file, "personal")
+	suite.EqualError(err, "No access token for doctl context personal in "+file)
+}
+
+func (suite *AuthSuite) TestFromDoctlCurrentContext() {
+	file := suite.writeFile("config.yaml", `access-token: default-token
+auth-contexts:
+  work: work-token
+context: work
+`)
+
+	token, err := FromDoctl(file, "")
+
+	suite.NoError(err)
+	suite.Equal("work-token", token)
+}
+
+func (suite *AuthSuite) TestCommand() {
+	command := &Command{Command: "echo", Args: []string{"command-token"}}
+
+	token, err := command.Token()
+
+	suite.NoError(err)
+	suite.Equal("command-token", token)
+}
+
+func (suite *AuthSuite) TestFailingCommand() {
+	command := &Command{Command: "false"}
+
+	_, err := command.Token()
+
+	suite.Error(err)
+	suite.Contains(err.Error(), "Token command false failed")
+}
+
+func (suite *AuthSuite) TestResolverReadsSources() {
+	os.Setenv("APPFILE_TEST_TOKEN", "env-token")
+	defer os.Unsetenv("APPFILE_TEST_TOKEN")
+	dir := suite.T().TempDir()
+	suite.Require().NoError(ioutil.WriteFile(filepath.Join(dir, "token"), []byte("file-token"), 0600))
+	config := suite.writeFile("config.yaml", "auth-contexts:\n  work: work-token\n")
+
+	resolver := NewStaticResolver("default-token")
+	resolver.DoctlConfig = config
+	fileSource := &Source{File: "token"}
+	fileSource.SetDir(dir)
+
+	for source, expected := range map[*Source]string{
+		nil:                         "default-token",
+		{Env: "APPFILE_TEST_TOKEN"}: "env-token",
+		fileSource:                  "file-token",
+		{Command: "echo", Args: []string{"command-token"}}: "command-token",
+		{Context: "work"}: "work-token",
+	} {
+		token, err := resolver.Token(source)
+		suite.NoError(err)
+		suite.Equal(expected, token)
+	}
+}
+
+func (suite *AuthSuite) TestResolverMissingEnv() {
+	_, err := NewStaticResolver("").Token(&Source{Env: "APPFILE_MISSING_TOKEN"})
+
+	suite.EqualError(err, "Failed to read access token from environment variable APPFILE_MISSING_TOKEN: Environment variable APPFILE_MISSING_TOKEN holding the access token is not set")
+}
+
+func (suite *AuthSuite) TestSourceValidate() {
+	suite.NoError((&Source{Env: "TOKEN"}).Validate())
+	suite.Error((&Source{}).Validate())
+	suite.Error((&Source{Env: "TOKEN", File: "token"}).Validate())
+	suite.Error((&Source{Env: "TOKEN", Args: []string{"read"}}).Validate())
+}
+
+func (suite *AuthSuite) writeFile(name string, content string) string {
+	file := filepath.Join(suite.T().TempDir(), name)
+	suite.Require().NoError(ioutil.WriteFile(file, []byte(content), 0600))
+
+	return file
+}
+
+func TestAuthSuite(t *testing.T) {
+	suite.Run(t, &AuthSuite{})
+}
@@ -0,0 +1,168 @@
+package auth
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/renehernandez/appfile/internal/errors"
+)
+
+// Source declares where to read an access token from. It can be written as
+// the name of the environment variable holding the token or as a map setting
+// one of env, file, command, with its args, or the doctl auth context
+type Source struct {
+	Env     string   `yaml:"env"`
+	File    string   `yaml:"file"`
+	Command string   `yaml:"command"`
+	Args    []string `yaml:"args"`
+	Context string   `yaml:"context"`
+
+	dir string
+}
+
+func (source *Source) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var env string
+	if err := unmarshal(&env); err == nil {
+		source.Env = env
+		return nil
+	}
+
+	type rawSource Source
+	var raw rawSource
+	if err := unmarshal(&raw); err != nil {
+		return err
+	}
+
+	*source = Source(raw)
+	return nil
+}
+
+// SetDir sets the directory that file paths are relative to and commands run from
+func (source *Source) SetDir(dir string) {
+	source.dir = dir
+}
+
+// Validate checks that the source sets exactly one place to read the token from
+func (source *Source) Validate() error {
+	set := 0
+	for _, value := range []string{source.Env, source.File, source.Command, source.Context} {
+		if value != "" {
+			set++
+		}
+	}
+
+	if set != 1 {
+		return fmt.Errorf("Token source must set exactly one of env, file, command or context")
+	}
+
+	if len(source.Args) > 0 && source.Command == "" {
+		return fmt.Errorf("Token source sets args without a command")
+	}
+
+	return nil
+}
+
+func (source *Source) String() string {
+	switch {
+	case source.Env != "":
+		return fmt.Sprintf("environment variable %s", source.Env)
+	case source.File != "":
+		return fmt.Sprintf("file %s", source.File)
+	case source.Command != "":
+		return fmt.Sprintf("command %s", strings.Join(append([]string{source.Command}, source.Args...), " "))
+	case source.Context != "":
+		return fmt.Sprintf("doctl context %s", source.Context)
+	}
+
+	return "empty token source"
+}
+
+func (source *Source) token(doctlConfig string) (string, error) {
+	if err := source.Validate(); err != nil {
+		return "", err
+	}
+
+	switch {
+	case source.Env != "":
+		token, ok := os.LookupEnv(source.Env)
+		if !ok || token == "" {
+			return "", fmt.Errorf("Environment variable %s holding the access token is not set", source.Env)
+		}
+		return token, nil
+	case source.File != "":
+		file := source.File
+		if !filepath.IsAbs(file) {
+			file = filepath.Join(source.dir, file)
+		}
+		return FromFile(file)
+	case source.Command != "":
+		command := &Command{Command: source.Command, Args: source.Args, dir: source.dir}
+		return command.Token()
+	}
+
+	if doctlConfig == "" {
+		var err error
+		if doctlConfig, err = DoctlConfigFile(); err != nil {
+			return "", err
+		}
+	}
+
+	return FromDoctl(doctlConfig, source.Context)
+}
+
+// Resolver reads the tokens of the sources, reading every source once
+type Resolver struct {
+	// Default returns the token for the apps without a token source
+	Default func() (string, error)
+	// DoctlConfig is the doctl configuration file read by the sources with a
+	// context. Defaults to the doctl location
+	DoctlConfig string
+
+	tokens map[string]string
+}
+
+// NewStaticResolver returns a resolver using token for the apps without a token source
+func NewStaticResolver(token string) *Resolver {
+	return &Resolver{
+		Default: func() (string, error) {
+			return token, nil
+		},
+	}
+}
+
+// Token returns the token of source, or the default token for a nil source
+func (resolver *Resolver) Token(source *Source) (string, error) {
+	if resolver.tokens == nil {
+		resolver.tokens = map[string]string{}
+	}
+
+	key := ""
+	if source != nil {
+		key = source.dir + "\x00" + source.String()
+	}
+
+	if token, ok := resolver.tokens[key]; ok {
+		return token, nil
+	}
+
+	var token string
+	var err error
+	if source == nil {
+		token, err = resolver.Default()
+	} else {
+		token, err = source.token(resolver.DoctlConfig)
+		if err != nil {
+			err = fmt.Errorf("Failed to read access token from %s: %s", source, err)
+		}
+	}
+
+	if err != nil {
+		return "", errors.Classify(errors.KindAuth, err)
+	}
+
+	resolver.tokens[key] = token
+
+	return token, nil
+}
@@ -2,32 +2,50 @@ package do
 
 import (
 	"context"
+	"time"
 
 	"github.com/digitalocean/godo"
-	"github.com/pkg/errors"
+	apperrors "github.com/renehernandez/appfile/internal/errors"
 )
 
-type AppService struct {
+// AppService manages the apps deployed to App Platform
+type AppService interface {
+	ListApps(ctx context.Context) ([]*godo.App, error)
+	ListInstancesSizes(ctx context.Context) ([]*godo.AppInstanceSize, error)
+	FindByName(ctx context.Context, appName string) (*godo.App, error)
+	Create(ctx context.Context, app *godo.App) (*godo.App, error)
+	Update(ctx context.Context, local *godo.App, remote *godo.App) (*godo.App, error)
+	Destroy(ctx context.Context, app *godo.App) error
+	// Propose validates the app spec and returns whether the app name is
+	// available, the cost of the app and the spec normalized by DigitalOcean
+	Propose(ctx context.Context, app *godo.App) (*godo.AppProposeResponse, error)
+	// WaitForDeployment waits for the deployment started by creating or
+	// updating the app to become active, and returns the app as deployed
+	WaitForDeployment(ctx context.Context, app *godo.App) (*godo.App, error)
+}
+
+// deploymentPollInterval is the time between the checks of a deployment in progress
+var deploymentPollInterval = 10 * time.Second
+
+type appService struct {
 	client *godo.Client
 }
 
-func NewAppService(token string) *AppService {
-	return &AppService{
-		client: godo.NewFromToken(token),
+func NewAppService(client *godo.Client) AppService {
+	return &appService{
+		client: client,
 	}
 }
 
-func (svc *AppService) ListApps() ([]*godo.App, error) {
+func (svc *appService) ListApps(ctx context.Context) ([]*godo.App, error) {
 	list := []*godo.App{}
-	ctx := context.TODO()
-
 	// create options. initially, these will be blank
 	opt := &godo.ListOptions{}
 
 	for {
 		apps, resp, err := svc.client.Apps.List(ctx, opt)
 		if err != nil {
-			return []*godo.App{}, err
+			return []*godo.App{}, apiError(err, "Failed to list apps")
 		}
 
 		// append the current page's droplets to our list
@@ -50,19 +68,17 @@ func (svc *AppService) ListApps() ([]*godo.App, error) {
 	return list, nil
 }
 
-func (svc *AppService) ListInstancesSizes() ([]*godo.AppInstanceSize, error) {
-	ctx := context.TODO()
-
+func (svc *appService) ListInstancesSizes(ctx context.Context) ([]*godo.AppInstanceSize, error) {
 	sizes, _, err := svc.client.Apps.ListInstanceSizes(ctx)
 	if err != nil {
-		return []*godo.AppInstanceSize{}, err
+		return []*godo.AppInstanceSize{}, apiError(err, "Failed to list instance sizes")
 	}
 
 	return sizes, nil
 }
 
-func (svc *AppService) FindByName(appName string) (*godo.App, error) {
-	apps, err := svc.ListApps()
+func (svc *appService) FindByName(ctx context.Context, appName string) (*godo.App, error) {
+	apps, err := svc.ListApps(ctx)
 	if err != nil {
 		return &godo.App{}, err
 	}
@@ -73,46 +89,79 @@ func (svc *AppService) FindByName(appName string) (*godo.App, error) {
 		}
 	}
 
-	return &godo.App{}, errors.New("App with name %s not found")
+	return &godo.App{}, apperrors.New(apperrors.KindNotFound, "App with name %s not found", appName)
 }
 
-func (svc *AppService) Create(app *godo.App) error {
-	ctx := context.TODO()
+func (svc *appService) Create(ctx context.Context, app *godo.App) (*godo.App, error) {
 	request := &godo.AppCreateRequest{Spec: app.Spec}
 
-	_, _, err := svc.client.Apps.Create(ctx, request)
+	created, _, err := svc.client.Apps.Create(ctx, request)
 	if err != nil {
-		return errors.Wrapf(err, "Failed to create new app from spec %s", app.Spec.Name)
+		return &godo.App{}, apiError(err, "Failed to create new app from spec %s", app.Spec.Name)
 	}
 
-	return nil
+	return created, nil
 }
 
-func (svc *AppService) Update(local *godo.App, remote *godo.App) error {
-	ctx := context.TODO()
+func (svc *appService) Update(ctx context.Context, local *godo.App, remote *godo.App) (*godo.App, error) {
 	request := &godo.AppUpdateRequest{Spec: local.Spec}
 
-	_, _, err := svc.client.Apps.Update(ctx, remote.ID, request)
+	updated, _, err := svc.client.Apps.Update(ctx, remote.ID, request)
 	if err != nil {
-		return errors.Wrapf(err, "Failed to update app from spec %s", local.Spec.Name)
+		return &godo.App{}, apiError(err, "Failed to update app from spec %s", local.Spec.Name)
 	}
 
-	return nil
+	return updated, nil
 }
 
-func (svc *AppService) Destroy(app *godo.App) error {
-	ctx := context.TODO()
-
+func (svc *appService) Destroy(ctx context.Context, app *godo.App) error {
 	_, err := svc.client.Apps.Delete(ctx, app.ID)
 	if err != nil {
-		return errors.Wrapf(err, "Failed to delete app %s", app.Spec.Name)
+		return apiError(err, "Failed to delete app %s", app.Spec.Name)
 	}
 
 	return nil
 }
 
-func (svc *AppService) Propose(app *godo.App) error {
-	ctx := context.TODO()
+func (svc *appService) WaitForDeployment(ctx context.Context, app *godo.App) (*godo.App, error) {
+	deploymentID := ""
+	if app.InProgressDeployment != nil {
+		deploymentID = app.InProgressDeployment.ID
+	}
+
+	for {
+		current, _, err := svc.client.Apps.Get(ctx, app.ID)
+		if err != nil {
+			return &godo.App{}, apiError(err, "Failed to read app %s", app.Spec.Name)
+		}
+
+		if current.InProgressDeployment != nil {
+			deploymentID = current.InProgressDeployment.ID
+		} else if current.ActiveDeployment != nil && (deploymentID == "" || current.ActiveDeployment.ID == deploymentID) {
+			return current, nil
+		} else if deploymentID != "" {
+			return &godo.App{}, svc.deploymentError(ctx, app, deploymentID)
+		}
+
+		select {
+		case <-ctx.Done():
+			return &godo.App{}, ctx.Err()
+		case <-time.After(deploymentPollInterval):
+		}
+	}
+}
+
+// deploymentError reports the deployment of the app that ended without becoming active
+func (svc *appService) deploymentError(ctx context.Context, app *godo.App, deploymentID string) error {
+	deployment, _, err := svc.client.Apps.GetDeployment(ctx, app.ID, deploymentID)
+	if err != nil {
+		return apiError(err, "Failed to read deployment %s of app %s", deploymentID, app.Spec.Name)
+	}
+
+	return apperrors.New(apperrors.KindDeployment, "Deployment %s of app %s ended in phase %s", deploymentID, app.Spec.Name, deployment.Phase)
+}
+
+func (svc *appService) Propose(ctx context.Context, app *godo.App) (*godo.AppProposeResponse, error) {
 	request := &godo.AppProposeRequest{
 		Spec: app.Spec,
 	}
@@ -121,7 +170,10 @@ func (svc *AppService) Propose(app *godo.App) error {
 		request.AppID = app.ID
 	}
 
-	_, _, err := svc.client.Apps.Propose(ctx, request)
+	response, _, err := svc.client.Apps.Propose(ctx, request)
+	if err != nil {
+		return &godo.AppProposeResponse{}, apiError(err, "Failed to propose app %s", app.Spec.Name)
+	}
 
-	return err
+	return response, nil
 }
@@ -0,0 +1,87 @@
+package do
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/digitalocean/godo"
+	apperrors "github.com/renehernandez/appfile/internal/errors"
+	"github.com/stretchr/testify/suite"
+)
+
+type AppServiceSuite struct {
+	suite.Suite
+
+	pollInterval time.Duration
+}
+
+func (suite *AppServiceSuite) SetupTest() {
+	suite.pollInterval = deploymentPollInterval
+	deploymentPollInterval = time.Millisecond
+}
+
+func (suite *AppServiceSuite) TearDownTest() {
+	deploymentPollInterval = suite.pollInterval
+}
+
+func (suite *AppServiceSuite) TestWaitForDeploymentUntilActive() {
+	responses := []string{
+		`{"app":{"id":"1","spec":{"name":"web"},"in_progress_deployment":{"id":"d1","phase":"BUILDING"}}}`,
+		`{"app":{"id":"1","spec":{"name":"web"},"active_deployment":{"id":"d1","phase":"ACTIVE"},"live_url":"https://web.ondigitalocean.app"}}`,
+	}
+	requests := 0
+	svc := suite.appService(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		suite.Equal("/v2/apps/1", r.URL.Path)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(responses[requests]))
+		requests++
+	}))
+
+	app, err := svc.WaitForDeployment(context.Background(), &godo.App{ID: "1", Spec: &godo.AppSpec{Name: "web"}})
+
+	suite.Require().NoError(err)
+	suite.Equal(2, requests)
+	suite.Equal("https://web.ondigitalocean.app", app.LiveURL)
+}
+
+func (suite *AppServiceSuite) TestWaitForDeploymentFailed() {
+	svc := suite.appService(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/v2/apps/1":
+			_, _ = w.Write([]byte(`{"app":{"id":"1","spec":{"name":"web"},"active_deployment":{"id":"d0","phase":"ACTIVE"}}}`))
+		case "/v2/apps/1/deployments/d1":
+			_, _ = w.Write([]byte(`{"deployment":{"id":"d1","phase":"ERROR"}}`))
+		default:
+			suite.Failf("Unexpected request", "path %s", r.URL.Path)
+		}
+	}))
+
+	_, err := svc.WaitForDeployment(context.Background(), &godo.App{
+		ID:                   "1",
+		Spec:                 &godo.AppSpec{Name: "web"},
+		InProgressDeployment: &godo.Deployment{ID: "d1"},
+	})
+
+	suite.EqualError(err, "Deployment d1 of app web ended in phase ERROR")
+	suite.Equal(apperrors.KindDeployment, apperrors.KindOf(err))
+}
+
+func (suite *AppServiceSuite) appService(handler http.Handler) AppService {
+	server := httptest.NewServer(handler)
+	suite.T().Cleanup(server.Close)
+
+	opts := DefaultClientOptions()
+	opts.APIURL = server.URL
+	backend, err := NewBackend(opts)
+	suite.Require().NoError(err)
+
+	return backend.AppService("token")
+}
+
+func TestAppServiceSuite(t *testing.T) {
+	suite.Run(t, &AppServiceSuite{})
+}
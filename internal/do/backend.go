@@ -0,0 +1,31 @@
+package do
+
+// Backend creates the services used to manage the App Platform resources
+type Backend interface {
+	AppService(token string) AppService
+	DomainService(token string) DomainService
+}
+
+type apiBackend struct {
+	factory *clientFactory
+}
+
+// NewBackend returns the backend using the DigitalOcean API
+func NewBackend(opts ClientOptions) (Backend, error) {
+	factory, err := newClientFactory(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return &apiBackend{
+		factory: factory,
+	}, nil
+}
+
+func (backend *apiBackend) AppService(token string) AppService {
+	return NewAppService(backend.factory.client(token))
+}
+
+func (backend *apiBackend) DomainService(token string) DomainService {
+	return NewDomainService(backend.factory.client(token))
+}
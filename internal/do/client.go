@@ -0,0 +1,140 @@
+package do
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+
+	"github.com/digitalocean/godo"
+	"github.com/pkg/errors"
+	"github.com/renehernandez/appfile/internal/log"
+	"golang.org/x/oauth2"
+)
+
+// ClientOptions configures the clients of the DigitalOcean API
+type ClientOptions struct {
+	Retry RetryOptions
+	// APIURL replaces the endpoint of the DigitalOcean API
+	APIURL string
+	// CAFile is a PEM file with certificate authorities trusted on top of the system ones
+	CAFile string
+	// InsecureSkipVerify disables the verification of the server certificates
+	InsecureSkipVerify bool
+}
+
+// DefaultClientOptions returns the options used when none are configured
+func DefaultClientOptions() ClientOptions {
+	return ClientOptions{
+		Retry: DefaultRetryOptions(),
+	}
+}
+
+// clientFactory creates API clients sharing the same transport and endpoint
+type clientFactory struct {
+	transport http.RoundTripper
+	baseURL   *url.URL
+}
+
+func newClientFactory(opts ClientOptions) (*clientFactory, error) {
+	factory := &clientFactory{}
+
+	if opts.APIURL != "" {
+		baseURL, err := parseAPIURL(opts.APIURL)
+		if err != nil {
+			return nil, err
+		}
+		log.Debugf("Using DigitalOcean API at %s", baseURL)
+		factory.baseURL = baseURL
+	}
+
+	transport, err := newHTTPTransport(opts)
+	if err != nil {
+		return nil, err
+	}
+	factory.transport = newRetryTransport(transport, opts.Retry)
+
+	return factory, nil
+}
+
+func (factory *clientFactory) client(token string) *godo.Client {
+	tokenSource := oauth2.StaticTokenSource(&oauth2.Token{AccessToken: token})
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, &http.Client{Transport: factory.transport})
+
+	client := godo.NewClient(oauth2.NewClient(ctx, tokenSource))
+	if factory.baseURL != nil {
+		client.BaseURL = factory.baseURL
+	}
+
+	return client
+}
+
+func parseAPIURL(apiURL string) (*url.URL, error) {
+	baseURL, err := url.Parse(apiURL)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Invalid API URL %s", apiURL)
+	}
+
+	if baseURL.Scheme != "http" && baseURL.Scheme != "https" || baseURL.Host == "" {
+		return nil, fmt.Errorf("Invalid API URL %s: must be an absolute http or https URL", apiURL)
+	}
+
+	// The API paths are absolute, so they always replace the path of the URL
+	if baseURL.Path != "" && baseURL.Path != "/" {
+		return nil, fmt.Errorf("Invalid API URL %s: must not include a path", apiURL)
+	}
+
+	return baseURL, nil
+}
+
+// newHTTPTransport returns the transport of the API clients. Proxies are read from
+// the HTTPS_PROXY, HTTP_PROXY and NO_PROXY environment variables
+func newHTTPTransport(opts ClientOptions) (*http.Transport, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = http.ProxyFromEnvironment
+
+	if opts.CAFile == "" && !opts.InsecureSkipVerify {
+		return transport, nil
+	}
+
+	tlsConfig := &tls.Config{}
+
+	if opts.CAFile != "" {
+		pool, err := certPool(opts.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if opts.InsecureSkipVerify {
+		log.Warningln("Verification of the DigitalOcean API certificates is disabled")
+		tlsConfig.InsecureSkipVerify = true
+	}
+
+	transport.TLSClientConfig = tlsConfig
+
+	return transport, nil
+}
+
+func certPool(caFile string) (*x509.CertPool, error) {
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		log.Debugf("Could not load system certificates, only trusting %s: %s", caFile, err)
+		pool = x509.NewCertPool()
+	}
+
+	content, err := ioutil.ReadFile(caFile)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to read CA file %s", caFile)
+	}
+
+	if !pool.AppendCertsFromPEM(content) {
+		return nil, fmt.Errorf("No PEM certificates found in CA file %s", caFile)
+	}
+
+	return pool, nil
+}
@@ -0,0 +1,85 @@
+package do
+
+import (
+	"context"
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/digitalocean/godo"
+	"github.com/stretchr/testify/suite"
+)
+
+type ClientSuite struct {
+	suite.Suite
+
+	server *httptest.Server
+}
+
+func (suite *ClientSuite) SetupTest() {
+	suite.server = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		suite.Equal("/v2/apps", r.URL.Path)
+		suite.Equal("Bearer token", r.Header.Get("Authorization"))
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"apps":[{"id":"1","spec":{"name":"web"}}],"links":{}}`))
+	}))
+}
+
+func (suite *ClientSuite) TearDownTest() {
+	suite.server.Close()
+}
+
+func (suite *ClientSuite) TestCustomAPIURLWithCAFile() {
+	caFile := filepath.Join(suite.T().TempDir(), "ca.pem")
+	cert := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: suite.server.Certificate().Raw})
+	suite.Require().NoError(ioutil.WriteFile(caFile, cert, 0600))
+
+	apps, err := suite.listApps(ClientOptions{APIURL: suite.server.URL, CAFile: caFile})
+
+	suite.Require().NoError(err)
+	suite.Len(apps, 1)
+}
+
+func (suite *ClientSuite) TestInsecureSkipVerify() {
+	apps, err := suite.listApps(ClientOptions{APIURL: suite.server.URL, InsecureSkipVerify: true})
+
+	suite.Require().NoError(err)
+	suite.Len(apps, 1)
+}
+
+func (suite *ClientSuite) TestUnknownAuthorityFails() {
+	opts := DefaultClientOptions()
+	opts.APIURL = suite.server.URL
+
+	_, err := suite.listApps(opts)
+
+	suite.Error(err)
+	suite.True(isCertificateError(err))
+}
+
+func (suite *ClientSuite) TestInvalidOptions() {
+	_, err := NewBackend(ClientOptions{APIURL: "localhost:8080"})
+	suite.EqualError(err, "Invalid API URL localhost:8080: must be an absolute http or https URL")
+
+	_, err = NewBackend(ClientOptions{APIURL: "http://localhost:8080/api"})
+	suite.EqualError(err, "Invalid API URL http://localhost:8080/api: must not include a path")
+
+	caFile := filepath.Join(suite.T().TempDir(), "ca.pem")
+	suite.Require().NoError(ioutil.WriteFile(caFile, []byte("not a certificate"), 0600))
+	_, err = NewBackend(ClientOptions{CAFile: caFile})
+	suite.EqualError(err, "No PEM certificates found in CA file "+caFile)
+}
+
+func (suite *ClientSuite) listApps(opts ClientOptions) ([]*godo.App, error) {
+	backend, err := NewBackend(opts)
+	suite.Require().NoError(err)
+
+	return backend.AppService("token").ListApps(context.Background())
+}
+
+func TestClientSuite(t *testing.T) {
+	suite.Run(t, &ClientSuite{})
+}
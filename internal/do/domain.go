@@ -5,52 +5,65 @@ import (
 	"fmt"
 
 	"github.com/digitalocean/godo"
-	"github.com/pkg/errors"
 	"github.com/renehernandez/appfile/internal/log"
 )
 
-type DomainService struct {
+// DomainService manages the DNS records of the app domains
+type DomainService interface {
+	DeleteRecord(ctx context.Context, domain *godo.AppDomainSpec) error
+}
+
+type domainService struct {
 	client *godo.Client
 }
 
-func NewDomainService(token string) *DomainService {
-	return &DomainService{
-		client: godo.NewFromToken(token),
+func NewDomainService(client *godo.Client) DomainService {
+	return &domainService{
+		client: client,
 	}
 }
 
-func (svc *DomainService) DeleteRecord(domain *godo.AppDomainSpec) error {
-	ctx := context.TODO()
-	record, err := svc.getCNAMERecord(domain)
+func (svc *domainService) DeleteRecord(ctx context.Context, domain *godo.AppDomainSpec) error {
+	record, err := svc.getCNAMERecord(ctx, domain)
 	if err != nil {
 		return err
 	}
 	if record.ID > 0 {
 		log.Debugf("Record to delete: %++v", record)
 		_, err = svc.client.Domains.DeleteRecord(ctx, domain.Zone, record.ID)
+		err = apiError(err, "Failed to delete %s record from DigitalOcean", domain.Domain)
 
 		if err == nil {
-			log.Infof("%s hostname deleted successfully from %s zone", domain.Domain, domain.Zone)
+			domainLogger(domain).Infof("Hostname deleted successfully")
 		}
 	}
 	return err
 }
 
-func (svc *DomainService) getCNAMERecord(domain *godo.AppDomainSpec) (*godo.DomainRecord, error) {
-	ctx := context.TODO()
+func (svc *domainService) getCNAMERecord(ctx context.Context, domain *godo.AppDomainSpec) (*godo.DomainRecord, error) {
 	opts := &godo.ListOptions{}
 
 	records, _, err := svc.client.Domains.RecordsByTypeAndName(ctx, domain.Zone, "CNAME", domain.Domain, opts)
 
 	if err != nil {
-		return &godo.DomainRecord{}, errors.Wrapf(err, "Failed to retrieve %s record from DigitalOcean", domain.Domain)
+		return &godo.DomainRecord{}, apiError(err, "Failed to retrieve %s record from DigitalOcean", domain.Domain)
 	}
 
 	if len(records) == 0 {
-		log.Warningf("%s CNAME record not found", domain.Domain)
+		domainLogger(domain).Warningf("CNAME record not found")
 		return &godo.DomainRecord{}, nil
 	} else if len(records) > 1 {
 		return &godo.DomainRecord{}, fmt.Errorf("Same %s CNAME record appeared more than once", domain.Domain)
 	}
 	return &records[0], nil
 }
+
+// domainLogger returns the logger of the operations on the records of a domain
+func domainLogger(domain *godo.AppDomainSpec) *log.Logger {
+	return log.With(log.Fields{
+		log.FieldComponent: "dns",
+		log.FieldOperation: "delete-record",
+		"domain":           domain.Domain,
+		"zone":             domain.Zone,
+	})
+}
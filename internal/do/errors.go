@@ -0,0 +1,32 @@
+package do
+
+import (
+	"net/http"
+
+	"github.com/digitalocean/godo"
+	"github.com/pkg/errors"
+	apperrors "github.com/renehernandez/appfile/internal/errors"
+)
+
+// apiError wraps an error returned by the DigitalOcean API with the formatted
+// message, classifying it by the status code of the response
+func apiError(err error, format string, args ...interface{}) error {
+	if err == nil {
+		return nil
+	}
+
+	kind := apperrors.KindAPI
+	var resp *godo.ErrorResponse
+	if errors.As(err, &resp) && resp.Response != nil {
+		switch resp.Response.StatusCode {
+		case http.StatusUnauthorized, http.StatusForbidden:
+			kind = apperrors.KindAuth
+		case http.StatusNotFound:
+			kind = apperrors.KindNotFound
+		case http.StatusBadRequest, http.StatusUnprocessableEntity:
+			kind = apperrors.KindValidation
+		}
+	}
+
+	return apperrors.Wrap(kind, errors.Wrapf(err, format, args...))
+}
@@ -0,0 +1,46 @@
+package do
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/digitalocean/godo"
+	apperrors "github.com/renehernandez/appfile/internal/errors"
+	"github.com/stretchr/testify/suite"
+)
+
+type ErrorsSuite struct {
+	suite.Suite
+}
+
+func (suite *ErrorsSuite) TestAPIErrorKindByStatus() {
+	cases := map[int]apperrors.Kind{
+		http.StatusUnauthorized:        apperrors.KindAuth,
+		http.StatusForbidden:           apperrors.KindAuth,
+		http.StatusNotFound:            apperrors.KindNotFound,
+		http.StatusUnprocessableEntity: apperrors.KindValidation,
+		http.StatusInternalServerError: apperrors.KindAPI,
+	}
+
+	for status, kind := range cases {
+		err := apiError(&godo.ErrorResponse{
+			Response: &http.Response{StatusCode: status, Request: &http.Request{}},
+			Message:  http.StatusText(status),
+		}, "Failed to update app from spec %s", "web")
+
+		suite.Equal(kind, apperrors.KindOf(err), "status %d", status)
+	}
+}
+
+func (suite *ErrorsSuite) TestAPIErrorWithoutResponse() {
+	err := apiError(fmt.Errorf("connection refused"), "Failed to list apps")
+
+	suite.EqualError(err, "Failed to list apps: connection refused")
+	suite.Equal(apperrors.KindAPI, apperrors.KindOf(err))
+	suite.Nil(apiError(nil, "Failed to list apps"))
+}
+
+func TestErrorsSuite(t *testing.T) {
+	suite.Run(t, &ErrorsSuite{})
+}
@@ -0,0 +1,247 @@
+package fake
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/digitalocean/godo"
+	"github.com/renehernandez/appfile/internal/errors"
+)
+
+type appService struct {
+	backend *Backend
+}
+
+func (svc *appService) ListApps(ctx context.Context) ([]*godo.App, error) {
+	if err := ctx.Err(); err != nil {
+		return []*godo.App{}, err
+	}
+
+	return svc.backend.Apps(), nil
+}
+
+func (svc *appService) ListInstancesSizes(ctx context.Context) ([]*godo.AppInstanceSize, error) {
+	return []*godo.AppInstanceSize{
+		{Name: "Basic XXS", Slug: "basic-xxs", CPUType: godo.AppInstanceSizeCPUType_Shared, CPUs: "1", MemoryBytes: "536870912", USDPerMonth: "5.00", TierSlug: "basic"},
+		{Name: "Basic XS", Slug: "basic-xs", CPUType: godo.AppInstanceSizeCPUType_Shared, CPUs: "1", MemoryBytes: "1073741824", USDPerMonth: "10.00", TierSlug: "basic"},
+		{Name: "Professional XS", Slug: "professional-xs", CPUType: godo.AppInstanceSizeCPUType_Shared, CPUs: "1", MemoryBytes: "1073741824", USDPerMonth: "12.00", TierSlug: "professional"},
+	}, nil
+}
+
+func (svc *appService) FindByName(ctx context.Context, appName string) (*godo.App, error) {
+	apps, err := svc.ListApps(ctx)
+	if err != nil {
+		return &godo.App{}, err
+	}
+
+	for _, app := range apps {
+		if app.Spec.Name == appName {
+			return app, nil
+		}
+	}
+
+	return &godo.App{}, errors.New(errors.KindNotFound, "App with name %s not found", appName)
+}
+
+func (svc *appService) Create(ctx context.Context, app *godo.App) (*godo.App, error) {
+	backend := svc.backend
+	backend.mu.Lock()
+	defer backend.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return &godo.App{}, err
+	}
+
+	if err := validateSpec(app.Spec); err != nil {
+		return &godo.App{}, err
+	}
+
+	if backend.nameTaken(app.Spec.Name, "") {
+		return &godo.App{}, errors.New(errors.KindValidation, "Failed to create new app from spec %s: an app with the same name already exists", app.Spec.Name)
+	}
+
+	created := &godo.App{
+		ID:        backend.nextID("app"),
+		CreatedAt: time.Now().UTC(),
+		TierSlug:  "basic",
+	}
+	backend.deploy(created, app.Spec)
+	backend.state.Apps = append(backend.state.Apps, created)
+
+	return cloneApp(created), backend.save()
+}
+
+func (svc *appService) Update(ctx context.Context, local *godo.App, remote *godo.App) (*godo.App, error) {
+	backend := svc.backend
+	backend.mu.Lock()
+	defer backend.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return &godo.App{}, err
+	}
+
+	if err := validateSpec(local.Spec); err != nil {
+		return &godo.App{}, err
+	}
+
+	i, ok := backend.findApp(remote.ID)
+	if !ok {
+		return &godo.App{}, errors.New(errors.KindNotFound, "Failed to update app from spec %s: app %s not found", local.Spec.Name, remote.ID)
+	}
+
+	if backend.nameTaken(local.Spec.Name, remote.ID) {
+		return &godo.App{}, errors.New(errors.KindValidation, "Failed to update app from spec %s: an app with the same name already exists", local.Spec.Name)
+	}
+
+	updated := backend.state.Apps[i]
+	backend.deploy(updated, local.Spec)
+
+	return cloneApp(updated), backend.save()
+}
+
+func (svc *appService) Destroy(ctx context.Context, app *godo.App) error {
+	backend := svc.backend
+	backend.mu.Lock()
+	defer backend.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	i, ok := backend.findApp(app.ID)
+	if !ok {
+		return errors.New(errors.KindNotFound, "Failed to delete app %s: app %s not found", app.Spec.Name, app.ID)
+	}
+
+	backend.state.Apps = append(backend.state.Apps[:i], backend.state.Apps[i+1:]...)
+
+	return backend.save()
+}
+
+// WaitForDeployment returns the app as stored, since the fake deployments
+// become active right away
+func (svc *appService) WaitForDeployment(ctx context.Context, app *godo.App) (*godo.App, error) {
+	backend := svc.backend
+	backend.mu.Lock()
+	defer backend.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return &godo.App{}, err
+	}
+
+	i, ok := backend.findApp(app.ID)
+	if !ok {
+		return &godo.App{}, errors.New(errors.KindNotFound, "Failed to read app %s: app %s not found", app.Spec.Name, app.ID)
+	}
+
+	return cloneApp(backend.state.Apps[i]), nil
+}
+
+// Propose prices the components with the fake instance sizes and returns the
+// spec with the defaults filled in. The name of an app is available if no
+// other app uses it
+func (svc *appService) Propose(ctx context.Context, app *godo.App) (*godo.AppProposeResponse, error) {
+	sizes, err := svc.ListInstancesSizes(ctx)
+	if err != nil {
+		return &godo.AppProposeResponse{}, err
+	}
+
+	backend := svc.backend
+	backend.mu.Lock()
+	defer backend.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return &godo.AppProposeResponse{}, err
+	}
+
+	if err := validateSpec(app.Spec); err != nil {
+		return &godo.AppProposeResponse{}, err
+	}
+
+	response := &godo.AppProposeResponse{
+		AppIsStatic:        isStatic(app.Spec),
+		AppNameAvailable:   !backend.nameTaken(app.Spec.Name, app.ID),
+		ExistingStaticApps: fmt.Sprint(backend.staticApps()),
+		MaxFreeStaticApps:  fmt.Sprint(maxFreeStaticApps),
+		Spec:               withDefaults(app.Spec),
+		AppCost:            appCost(app.Spec, sizes),
+	}
+
+	for i := 2; !response.AppNameAvailable && response.AppNameSuggestion == ""; i++ {
+		suggestion := fmt.Sprintf("%s-%d", app.Spec.Name, i)
+		if !backend.nameTaken(suggestion, "") {
+			response.AppNameSuggestion = suggestion
+		}
+	}
+
+	return response, nil
+}
+
+// maxFreeStaticApps is the number of static apps free of charge per account
+const maxFreeStaticApps = 3
+
+// defaultInstanceSize is the size of the components without instance size
+const defaultInstanceSize = "basic-xxs"
+
+// defaultHTTPPort is the port of the services without HTTP port
+const defaultHTTPPort = 8080
+
+// staticApps returns the number of apps with only static sites. The backend must be locked
+func (backend *Backend) staticApps() int {
+	count := 0
+	for _, app := range backend.state.Apps {
+		if isStatic(app.Spec) {
+			count++
+		}
+	}
+
+	return count
+}
+
+func isStatic(spec *godo.AppSpec) bool {
+	return len(spec.StaticSites) > 0 && len(spec.Services) == 0 && len(spec.Workers) == 0 && len(spec.Jobs) == 0
+}
+
+// appCost returns the monthly cost of the services, workers and jobs of the spec
+func appCost(spec *godo.AppSpec, sizes []*godo.AppInstanceSize) float32 {
+	prices := map[string]float32{}
+	for _, size := range sizes {
+		price, err := strconv.ParseFloat(size.USDPerMonth, 32)
+		if err == nil {
+			prices[size.Slug] = float32(price)
+		}
+	}
+
+	cost := float32(0)
+	addComponent := func(slug string, count int64) {
+		if slug == "" {
+			slug = defaultInstanceSize
+		}
+		if count == 0 {
+			count = 1
+		}
+		cost += prices[slug] * float32(count)
+	}
+
+	for _, service := range spec.Services {
+		addComponent(service.InstanceSizeSlug, service.InstanceCount)
+	}
+	for _, worker := range spec.Workers {
+		addComponent(worker.InstanceSizeSlug, worker.InstanceCount)
+	}
+	for _, job := range spec.Jobs {
+		addComponent(job.InstanceSizeSlug, job.InstanceCount)
+	}
+
+	return cost
+}
+
+func validateSpec(spec *godo.AppSpec) error {
+	if spec == nil || spec.Name == "" {
+		return errors.New(errors.KindValidation, "App spec must specify a name")
+	}
+
+	return nil
+}
@@ -0,0 +1,280 @@
+// Package fake provides an in-memory App Platform backend to run appfile
+// without access to the DigitalOcean API
+package fake
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/digitalocean/godo"
+	"github.com/pkg/errors"
+	"github.com/renehernandez/appfile/internal/do"
+)
+
+// State holds the resources simulated by the backend
+type State struct {
+	Apps    []*godo.App                    `json:"apps"`
+	Records map[string][]godo.DomainRecord `json:"records"`
+	LastID  int                            `json:"last_id"`
+}
+
+// Backend simulates apps, deployments and DNS records in memory.
+// The services it creates share the same state and ignore the access token
+type Backend struct {
+	mu    sync.Mutex
+	state *State
+	file  string
+}
+
+// NewBackend returns a backend without any app or record
+func NewBackend() *Backend {
+	return &Backend{
+		state: &State{
+			Apps:    []*godo.App{},
+			Records: map[string][]godo.DomainRecord{},
+		},
+	}
+}
+
+// NewBackendFromFile returns a backend loading its state from file, if it exists,
+// and saving it after every change, so the state is kept between runs
+func NewBackendFromFile(file string) (*Backend, error) {
+	backend := NewBackend()
+	backend.file = file
+
+	content, err := ioutil.ReadFile(file)
+	if os.IsNotExist(err) {
+		return backend, nil
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to read fake backend state from %s", file)
+	}
+
+	if err = json.Unmarshal(content, backend.state); err != nil {
+		return nil, errors.Wrapf(err, "Failed to parse fake backend state from %s", file)
+	}
+
+	if backend.state.Records == nil {
+		backend.state.Records = map[string][]godo.DomainRecord{}
+	}
+
+	return backend, nil
+}
+
+func (backend *Backend) AppService(token string) do.AppService {
+	return &appService{backend: backend}
+}
+
+func (backend *Backend) DomainService(token string) do.DomainService {
+	return &domainService{backend: backend}
+}
+
+// Apps returns a copy of the apps in the backend
+func (backend *Backend) Apps() []*godo.App {
+	backend.mu.Lock()
+	defer backend.mu.Unlock()
+
+	apps := []*godo.App{}
+	for _, app := range backend.state.Apps {
+		apps = append(apps, cloneApp(app))
+	}
+
+	return apps
+}
+
+// AddApp creates an app from spec and deploys it, as if it was created outside of appfile
+func (backend *Backend) AddApp(spec *godo.AppSpec) (*godo.App, error) {
+	return backend.AppService("").Create(context.Background(), &godo.App{Spec: spec})
+}
+
+// Records returns the DNS records of zone
+func (backend *Backend) Records(zone string) []godo.DomainRecord {
+	backend.mu.Lock()
+	defer backend.mu.Unlock()
+
+	return append([]godo.DomainRecord{}, backend.state.Records[zone]...)
+}
+
+// AddRecord adds a DNS record to zone
+func (backend *Backend) AddRecord(zone string, record godo.DomainRecord) error {
+	backend.mu.Lock()
+	defer backend.mu.Unlock()
+
+	backend.state.LastID++
+	record.ID = backend.state.LastID
+	backend.state.Records[zone] = append(backend.state.Records[zone], record)
+
+	return backend.save()
+}
+
+func (backend *Backend) nextID(kind string) string {
+	backend.state.LastID++
+	return fmt.Sprintf("fake-%s-%d", kind, backend.state.LastID)
+}
+
+func (backend *Backend) findApp(id string) (int, bool) {
+	for i, app := range backend.state.Apps {
+		if app.ID == id {
+			return i, true
+		}
+	}
+
+	return -1, false
+}
+
+func (backend *Backend) nameTaken(name string, id string) bool {
+	for _, app := range backend.state.Apps {
+		if app.Spec.Name == name && app.ID != id {
+			return true
+		}
+	}
+
+	return false
+}
+
+// deploy creates a new active deployment of the app from spec, superseding the previous one
+func (backend *Backend) deploy(app *godo.App, spec *godo.AppSpec) {
+	now := time.Now().UTC()
+
+	var previousID string
+	if app.ActiveDeployment != nil {
+		previousID = app.ActiveDeployment.ID
+	}
+
+	app.Spec = withDefaults(spec)
+	app.UpdatedAt = now
+	app.LastDeploymentCreatedAt = now
+	app.LastDeploymentActiveAt = now
+	app.LiveURL = fmt.Sprintf("https://%s-%s.ondigitalocean.app", spec.Name, app.ID)
+	app.LiveURLBase = app.LiveURL
+	app.DefaultIngress = app.LiveURL
+	app.LiveDomain = fmt.Sprintf("%s-%s.ondigitalocean.app", spec.Name, app.ID)
+	app.InProgressDeployment = nil
+	app.ActiveDeployment = &godo.Deployment{
+		ID:                   backend.nextID("deployment"),
+		Spec:                 withDefaults(spec),
+		Phase:                godo.DeploymentPhase_Active,
+		PhaseLastUpdatedAt:   now,
+		CreatedAt:            now,
+		UpdatedAt:            now,
+		Cause:                "appfile",
+		PreviousDeploymentID: previousID,
+	}
+
+	for _, domain := range spec.Domains {
+		if domain.Domain == "" || domain.Zone == "" || backend.hasRecord(domain.Zone, domain.Domain) {
+			continue
+		}
+
+		backend.state.LastID++
+		backend.state.Records[domain.Zone] = append(backend.state.Records[domain.Zone], godo.DomainRecord{
+			ID:   backend.state.LastID,
+			Type: "CNAME",
+			Name: domain.Domain,
+			Data: app.LiveDomain + ".",
+		})
+	}
+}
+
+func (backend *Backend) hasRecord(zone string, name string) bool {
+	for _, record := range backend.state.Records[zone] {
+		if record.Type == "CNAME" && record.Name == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// save writes the state to the backend file, if any. Must be called with the lock held
+func (backend *Backend) save() error {
+	if backend.file == "" {
+		return nil
+	}
+
+	content, err := json.MarshalIndent(backend.state, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "Failed to serialize fake backend state")
+	}
+
+	if err = ioutil.WriteFile(backend.file, content, 0644); err != nil {
+		return errors.Wrapf(err, "Failed to write fake backend state to %s", backend.file)
+	}
+
+	return nil
+}
+
+func cloneApp(app *godo.App) *godo.App {
+	var copy godo.App
+	clone(app, &copy)
+	return &copy
+}
+
+func cloneSpec(spec *godo.AppSpec) *godo.AppSpec {
+	var copy godo.AppSpec
+	clone(spec, &copy)
+	return &copy
+}
+
+// withDefaults returns a copy of spec with the values DigitalOcean fills in
+// for the fields left empty
+func withDefaults(spec *godo.AppSpec) *godo.AppSpec {
+	copy := cloneSpec(spec)
+
+	for _, service := range copy.Services {
+		if service.InstanceCount == 0 {
+			service.InstanceCount = 1
+		}
+		if service.InstanceSizeSlug == "" {
+			service.InstanceSizeSlug = defaultInstanceSize
+		}
+		if service.HTTPPort == 0 {
+			service.HTTPPort = defaultHTTPPort
+		}
+		if len(service.Routes) == 0 {
+			service.Routes = []*godo.AppRouteSpec{{Path: "/"}}
+		}
+	}
+
+	for _, site := range copy.StaticSites {
+		if len(site.Routes) == 0 {
+			site.Routes = []*godo.AppRouteSpec{{Path: "/"}}
+		}
+	}
+
+	for _, worker := range copy.Workers {
+		if worker.InstanceCount == 0 {
+			worker.InstanceCount = 1
+		}
+		if worker.InstanceSizeSlug == "" {
+			worker.InstanceSizeSlug = defaultInstanceSize
+		}
+	}
+
+	for _, job := range copy.Jobs {
+		if job.InstanceCount == 0 {
+			job.InstanceCount = 1
+		}
+		if job.InstanceSizeSlug == "" {
+			job.InstanceSizeSlug = defaultInstanceSize
+		}
+	}
+
+	return copy
+}
+
+func clone(src interface{}, dst interface{}) {
+	content, err := json.Marshal(src)
+	if err != nil {
+		panic(fmt.Errorf("bug: cannot clone %T: %s", src, err))
+	}
+
+	if err = json.Unmarshal(content, dst); err != nil {
+		panic(fmt.Errorf("bug: cannot clone %T: %s", src, err))
+	}
+}
@@ -0,0 +1,100 @@
+package fake
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/digitalocean/godo"
+	"github.com/stretchr/testify/suite"
+)
+
+type BackendSuite struct {
+	suite.Suite
+}
+
+func (suite *BackendSuite) TestCreateRejectsDuplicateNames() {
+	backend := NewBackend()
+	svc := backend.AppService("token")
+
+	_, err := svc.Create(context.Background(), &godo.App{Spec: &godo.AppSpec{Name: "web"}})
+	suite.Require().NoError(err)
+
+	_, err = svc.Create(context.Background(), &godo.App{Spec: &godo.AppSpec{Name: "web"}})
+	suite.Error(err)
+
+	proposal, err := svc.Propose(context.Background(), &godo.App{Spec: &godo.AppSpec{Name: "web"}})
+	suite.Require().NoError(err)
+	suite.False(proposal.AppNameAvailable)
+	suite.Equal("web-2", proposal.AppNameSuggestion)
+}
+
+func (suite *BackendSuite) TestProposePricesComponents() {
+	svc := NewBackend().AppService("token")
+
+	proposal, err := svc.Propose(context.Background(), &godo.App{Spec: &godo.AppSpec{
+		Name:     "web",
+		Services: []*godo.AppServiceSpec{{Name: "api", InstanceCount: 2, InstanceSizeSlug: "basic-xs"}},
+		Workers:  []*godo.AppWorkerSpec{{Name: "queue"}},
+	}})
+
+	suite.Require().NoError(err)
+	suite.True(proposal.AppNameAvailable)
+	suite.False(proposal.AppIsStatic)
+	suite.Equal(float32(25), proposal.AppCost)
+}
+
+func (suite *BackendSuite) TestUpdateCreatesNewDeployment() {
+	backend := NewBackend()
+	svc := backend.AppService("token")
+
+	created, err := svc.Create(context.Background(), &godo.App{Spec: &godo.AppSpec{Name: "web"}})
+	suite.Require().NoError(err)
+	suite.Equal(godo.DeploymentPhase_Active, created.ActiveDeployment.Phase)
+
+	updated, err := svc.Update(context.Background(), &godo.App{Spec: &godo.AppSpec{Name: "web", Region: "nyc"}}, created)
+	suite.Require().NoError(err)
+
+	suite.Equal(created.ID, updated.ID)
+	suite.Equal("nyc", updated.Spec.Region)
+	suite.Equal(created.ActiveDeployment.ID, updated.ActiveDeployment.PreviousDeploymentID)
+}
+
+func (suite *BackendSuite) TestDeleteRecord() {
+	backend := NewBackend()
+	domain := &godo.AppDomainSpec{Domain: "web.example.com", Zone: "example.com"}
+
+	_, err := backend.AppService("token").Create(context.Background(), &godo.App{Spec: &godo.AppSpec{
+		Name:    "web",
+		Domains: []*godo.AppDomainSpec{domain},
+	}})
+	suite.Require().NoError(err)
+	suite.Len(backend.Records("example.com"), 1)
+
+	suite.NoError(backend.DomainService("token").DeleteRecord(context.Background(), domain))
+	suite.Empty(backend.Records("example.com"))
+}
+
+func (suite *BackendSuite) TestStateIsKeptInFile() {
+	file := filepath.Join(suite.T().TempDir(), "state.json")
+
+	backend, err := NewBackendFromFile(file)
+	suite.Require().NoError(err)
+	_, err = backend.AddApp(&godo.AppSpec{Name: "web"})
+	suite.Require().NoError(err)
+
+	reloaded, err := NewBackendFromFile(file)
+	suite.Require().NoError(err)
+
+	apps := reloaded.Apps()
+	suite.Len(apps, 1)
+	suite.Equal("web", apps[0].Spec.Name)
+
+	app, err := reloaded.AppService("token").Create(context.Background(), &godo.App{Spec: &godo.AppSpec{Name: "worker"}})
+	suite.Require().NoError(err)
+	suite.NotEqual(apps[0].ID, app.ID)
+}
+
+func TestBackendSuite(t *testing.T) {
+	suite.Run(t, &BackendSuite{})
+}
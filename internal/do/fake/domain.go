@@ -0,0 +1,44 @@
+package fake
+
+import (
+	"context"
+
+	"github.com/digitalocean/godo"
+	"github.com/renehernandez/appfile/internal/log"
+)
+
+type domainService struct {
+	backend *Backend
+}
+
+func (svc *domainService) DeleteRecord(ctx context.Context, domain *godo.AppDomainSpec) error {
+	backend := svc.backend
+	backend.mu.Lock()
+	defer backend.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	records := backend.state.Records[domain.Zone]
+	for i, record := range records {
+		if record.Type == "CNAME" && record.Name == domain.Domain {
+			backend.state.Records[domain.Zone] = append(records[:i], records[i+1:]...)
+			domainLogger(domain).Infof("Hostname deleted successfully")
+			return backend.save()
+		}
+	}
+
+	domainLogger(domain).Warningf("CNAME record not found")
+	return nil
+}
+
+// domainLogger returns the logger of the operations on the records of a domain
+func domainLogger(domain *godo.AppDomainSpec) *log.Logger {
+	return log.With(log.Fields{
+		log.FieldComponent: "dns",
+		log.FieldOperation: "delete-record",
+		"domain":           domain.Domain,
+		"zone":             domain.Zone,
+	})
+}
@@ -0,0 +1,263 @@
+package do
+
+import (
+	"crypto/x509"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/renehernandez/appfile/internal/log"
+)
+
+const (
+	// DefaultMaxRetries is the number of times a failed request is retried by default
+	DefaultMaxRetries = 4
+
+	defaultBaseDelay = 500 * time.Millisecond
+	defaultMaxDelay  = 30 * time.Second
+
+	headerRetryAfter         = "Retry-After"
+	headerRateLimitRemaining = "RateLimit-Remaining"
+	headerRateLimitReset     = "RateLimit-Reset"
+)
+
+// RetryOptions configures how failed requests to the DigitalOcean API are retried
+type RetryOptions struct {
+	// MaxRetries is the maximum number of retries of a request. Zero disables retries
+	MaxRetries int
+	// BaseDelay is the delay before the first retry, doubled on every attempt
+	BaseDelay time.Duration
+	// MaxDelay caps the backoff delay. Requests asking to wait longer are not retried
+	MaxDelay time.Duration
+	// RetryNonIdempotent retries failed POST and PATCH requests, which may
+	// have been applied before failing
+	RetryNonIdempotent bool
+}
+
+// DefaultRetryOptions returns the options used when none are configured
+func DefaultRetryOptions() RetryOptions {
+	return RetryOptions{
+		MaxRetries: DefaultMaxRetries,
+		BaseDelay:  defaultBaseDelay,
+		MaxDelay:   defaultMaxDelay,
+	}
+}
+
+// retryTransport retries requests failing with network errors, 429 or 5xx responses.
+// Rate limited requests are always retried since the API did not process them,
+// the rest only when the request is idempotent, unless configured otherwise
+type retryTransport struct {
+	base http.RoundTripper
+	opts RetryOptions
+	now  func() time.Time
+}
+
+func newRetryTransport(base http.RoundTripper, opts RetryOptions) *retryTransport {
+	if opts.BaseDelay <= 0 {
+		opts.BaseDelay = defaultBaseDelay
+	}
+	if opts.MaxDelay <= 0 {
+		opts.MaxDelay = defaultMaxDelay
+	}
+
+	return &retryTransport{
+		base: base,
+		opts: opts,
+		now:  time.Now,
+	}
+}
+
+// RoundTrip sends the request, retrying it on a clone with a fresh body so
+// that the request of the caller is never modified
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	attemptReq := req
+	for attempt := 1; ; attempt++ {
+		resp, err := t.base.RoundTrip(attemptReq)
+
+		if attempt > 1 && err == nil && isDeleted(req, resp) {
+			requestLogger(req).Debugf("Retried delete found nothing to delete, the previous attempt deleted it")
+			return deletedResponse(attemptReq, resp), nil
+		}
+
+		if attempt > t.opts.MaxRetries || !t.shouldRetry(req, resp, err) {
+			return resp, err
+		}
+
+		delay, ok := t.delay(attempt, resp)
+		if !ok {
+			requestLogger(req).Debugf("Not retrying: requested wait of %s exceeds the maximum delay of %s", delay, t.opts.MaxDelay)
+			return resp, err
+		}
+
+		body, rewound := rewindBody(req)
+		if !rewound {
+			return resp, err
+		}
+
+		requestLogger(req).Debugf("Retrying in %s (retry %d of %d): %s", delay.Round(time.Millisecond), attempt, t.opts.MaxRetries, failureReason(resp, err))
+
+		if resp != nil {
+			_, _ = io.Copy(ioutil.Discard, resp.Body)
+			resp.Body.Close()
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		case <-timer.C:
+		}
+
+		attemptReq = req.Clone(req.Context())
+		attemptReq.Body = body
+	}
+}
+
+// isDeleted reports whether a retried DELETE request got a 404 response,
+// meaning that a previous attempt deleted the resource before failing
+func isDeleted(req *http.Request, resp *http.Response) bool {
+	return req.Method == http.MethodDelete && resp.StatusCode == http.StatusNotFound
+}
+
+// deletedResponse replaces the 404 response of a retried DELETE request with
+// the successful response the first attempt would have returned
+func deletedResponse(req *http.Request, resp *http.Response) *http.Response {
+	_, _ = io.Copy(ioutil.Discard, resp.Body)
+	resp.Body.Close()
+
+	return &http.Response{
+		Status:     "204 No Content",
+		StatusCode: http.StatusNoContent,
+		Proto:      resp.Proto,
+		ProtoMajor: resp.ProtoMajor,
+		ProtoMinor: resp.ProtoMinor,
+		Header:     http.Header{},
+		Body:       http.NoBody,
+		Request:    req,
+	}
+}
+
+func (t *retryTransport) shouldRetry(req *http.Request, resp *http.Response, err error) bool {
+	if err != nil {
+		if req.Context().Err() != nil || isCertificateError(err) {
+			return false
+		}
+		return t.retriesMethod(req.Method)
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests:
+		return true
+	case resp.StatusCode >= 500 && resp.StatusCode != http.StatusNotImplemented:
+		return t.retriesMethod(req.Method)
+	}
+
+	return false
+}
+
+// isCertificateError reports whether the server certificate was rejected, which retries won't fix
+func isCertificateError(err error) bool {
+	var unknownAuthority x509.UnknownAuthorityError
+	var invalid x509.CertificateInvalidError
+	var hostname x509.HostnameError
+
+	return errors.As(err, &unknownAuthority) || errors.As(err, &invalid) || errors.As(err, &hostname)
+}
+
+func (t *retryTransport) retriesMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return true
+	}
+
+	return t.opts.RetryNonIdempotent
+}
+
+// delay returns how long to wait before the next attempt, honoring the wait
+// requested by the API. It returns false when the API asks to wait longer than the maximum delay
+func (t *retryTransport) delay(attempt int, resp *http.Response) (time.Duration, bool) {
+	if resp != nil {
+		if requested, ok := t.requestedDelay(resp); ok {
+			return requested, requested <= t.opts.MaxDelay
+		}
+	}
+
+	backoff := t.opts.BaseDelay << uint(attempt-1)
+	if backoff <= 0 || backoff > t.opts.MaxDelay {
+		backoff = t.opts.MaxDelay
+	}
+
+	// Full jitter spreads the retries of concurrent clients
+	return time.Duration(rand.Int63n(int64(backoff)) + 1), true
+}
+
+// requestedDelay reads the wait requested through the Retry-After header or,
+// once the rate limit is exhausted, the RateLimit-Reset header
+func (t *retryTransport) requestedDelay(resp *http.Response) (time.Duration, bool) {
+	if value := resp.Header.Get(headerRetryAfter); value != "" {
+		if seconds, err := strconv.Atoi(value); err == nil {
+			return nonNegative(time.Duration(seconds) * time.Second), true
+		}
+
+		if date, err := http.ParseTime(value); err == nil {
+			return nonNegative(date.Sub(t.now())), true
+		}
+	}
+
+	if resp.StatusCode == http.StatusTooManyRequests && resp.Header.Get(headerRateLimitRemaining) == "0" {
+		if reset, err := strconv.ParseInt(resp.Header.Get(headerRateLimitReset), 10, 64); err == nil {
+			return nonNegative(time.Unix(reset, 0).Sub(t.now())), true
+		}
+	}
+
+	return 0, false
+}
+
+func nonNegative(delay time.Duration) time.Duration {
+	if delay < 0 {
+		return 0
+	}
+
+	return delay
+}
+
+// rewindBody returns a fresh copy of the request body for the next attempt
+func rewindBody(req *http.Request) (io.ReadCloser, bool) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return req.Body, true
+	}
+
+	if req.GetBody == nil {
+		return nil, false
+	}
+
+	body, err := req.GetBody()
+	if err != nil {
+		return nil, false
+	}
+
+	return body, true
+}
+
+func failureReason(resp *http.Response, err error) string {
+	if err != nil {
+		return err.Error()
+	}
+
+	return resp.Status
+}
+
+// requestLogger returns the logger of the retries of an API request
+func requestLogger(req *http.Request) *log.Logger {
+	return log.With(log.Fields{
+		log.FieldComponent: "api",
+		log.FieldOperation: "retry",
+		"method":           req.Method,
+		"url":              req.URL.String(),
+	})
+}
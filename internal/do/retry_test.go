@@ -0,0 +1,223 @@
+package do
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type RetrySuite struct {
+	suite.Suite
+}
+
+func (suite *RetrySuite) TestRetriesServerErrorsOnIdempotentRequests() {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	resp, err := suite.client(RetryOptions{MaxRetries: 3}).Get(server.URL)
+
+	suite.Require().NoError(err)
+	suite.Equal(http.StatusOK, resp.StatusCode)
+	suite.Equal(int32(3), atomic.LoadInt32(&calls))
+}
+
+func (suite *RetrySuite) TestStopsAfterMaxRetries() {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	resp, err := suite.client(RetryOptions{MaxRetries: 2}).Get(server.URL)
+
+	suite.Require().NoError(err)
+	suite.Equal(http.StatusBadGateway, resp.StatusCode)
+	suite.Equal(int32(3), atomic.LoadInt32(&calls))
+}
+
+func (suite *RetrySuite) TestDoesNotRetryNonIdempotentRequestsByDefault() {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	resp, err := suite.client(RetryOptions{MaxRetries: 3}).Post(server.URL, "application/json", strings.NewReader(`{}`))
+
+	suite.Require().NoError(err)
+	suite.Equal(http.StatusInternalServerError, resp.StatusCode)
+	suite.Equal(int32(1), atomic.LoadInt32(&calls))
+}
+
+func (suite *RetrySuite) TestRetriesNonIdempotentRequestsWhenConfigured() {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		suite.Equal(`{"name":"web"}`, string(body))
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+
+	resp, err := suite.client(RetryOptions{MaxRetries: 3, RetryNonIdempotent: true}).Post(server.URL, "application/json", strings.NewReader(`{"name":"web"}`))
+
+	suite.Require().NoError(err)
+	suite.Equal(http.StatusCreated, resp.StatusCode)
+	suite.Equal(int32(2), atomic.LoadInt32(&calls))
+}
+
+func (suite *RetrySuite) TestRetriesRateLimitedRequests() {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+
+	resp, err := suite.client(RetryOptions{MaxRetries: 3}).Post(server.URL, "application/json", strings.NewReader(`{}`))
+
+	suite.Require().NoError(err)
+	suite.Equal(http.StatusCreated, resp.StatusCode)
+	suite.Equal(int32(2), atomic.LoadInt32(&calls))
+}
+
+func (suite *RetrySuite) TestDoesNotRetryClientErrors() {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusUnprocessableEntity)
+	}))
+	defer server.Close()
+
+	resp, err := suite.client(RetryOptions{MaxRetries: 3}).Get(server.URL)
+
+	suite.Require().NoError(err)
+	suite.Equal(http.StatusUnprocessableEntity, resp.StatusCode)
+	suite.Equal(int32(1), atomic.LoadInt32(&calls))
+}
+
+func (suite *RetrySuite) TestRetriesDoNotModifyTheRequest() {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		suite.Equal(`{"name":"web"}`, string(body))
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	req, err := http.NewRequest(http.MethodPut, server.URL, strings.NewReader(`{"name":"web"}`))
+	suite.Require().NoError(err)
+	body := req.Body
+
+	resp, err := suite.client(RetryOptions{MaxRetries: 3}).Do(req)
+
+	suite.Require().NoError(err)
+	suite.Equal(http.StatusOK, resp.StatusCode)
+	suite.Equal(int32(2), atomic.LoadInt32(&calls))
+	suite.True(body == req.Body, "request body was replaced")
+}
+
+func (suite *RetrySuite) TestRetriedDeleteOfDeletedResourceSucceeds() {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	req, err := http.NewRequest(http.MethodDelete, server.URL, nil)
+	suite.Require().NoError(err)
+
+	resp, err := suite.client(RetryOptions{MaxRetries: 3}).Do(req)
+
+	suite.Require().NoError(err)
+	suite.Equal(http.StatusNoContent, resp.StatusCode)
+	suite.Equal(int32(2), atomic.LoadInt32(&calls))
+}
+
+func (suite *RetrySuite) TestDeleteOfMissingResourceFails() {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	req, err := http.NewRequest(http.MethodDelete, server.URL, nil)
+	suite.Require().NoError(err)
+
+	resp, err := suite.client(RetryOptions{MaxRetries: 3}).Do(req)
+
+	suite.Require().NoError(err)
+	suite.Equal(http.StatusNotFound, resp.StatusCode)
+}
+
+func (suite *RetrySuite) TestRequestedDelay() {
+	now := time.Unix(1000, 0)
+	transport := newRetryTransport(http.DefaultTransport, RetryOptions{MaxRetries: 1, MaxDelay: time.Minute})
+	transport.now = func() time.Time { return now }
+
+	resp := &http.Response{StatusCode: http.StatusTooManyRequests, Header: http.Header{}}
+	resp.Header.Set("Retry-After", "7")
+	delay, ok := transport.delay(1, resp)
+	suite.True(ok)
+	suite.Equal(7*time.Second, delay)
+
+	resp.Header = http.Header{}
+	resp.Header.Set("RateLimit-Remaining", "0")
+	resp.Header.Set("RateLimit-Reset", "1030")
+	delay, ok = transport.delay(1, resp)
+	suite.True(ok)
+	suite.Equal(30*time.Second, delay)
+
+	resp.Header.Set("RateLimit-Reset", "4600")
+	_, ok = transport.delay(1, resp)
+	suite.False(ok)
+}
+
+func (suite *RetrySuite) TestBackoffIsCapped() {
+	transport := newRetryTransport(http.DefaultTransport, RetryOptions{BaseDelay: time.Second, MaxDelay: 3 * time.Second})
+	resp := &http.Response{StatusCode: http.StatusServiceUnavailable, Header: http.Header{}}
+
+	for attempt := 1; attempt <= 10; attempt++ {
+		delay, ok := transport.delay(attempt, resp)
+		suite.True(ok)
+		suite.True(delay > 0 && delay <= 3*time.Second, "delay %s out of range", delay)
+	}
+}
+
+func (suite *RetrySuite) client(opts RetryOptions) *http.Client {
+	opts.BaseDelay = time.Millisecond
+	return &http.Client{Transport: newRetryTransport(http.DefaultTransport, opts)}
+}
+
+func TestRetrySuite(t *testing.T) {
+	suite.Run(t, &RetrySuite{})
+}
@@ -36,12 +36,41 @@ func (e Environment) deepCopy() (Environment, error) {
 }
 
 func (e *Environment) Merge(other *Environment) (*Environment, error) {
+	return e.MergeWithOptions(other, nil)
+}
+
+// MergeWithOptions returns a copy of the environment with the values of other
+// merged on top. Maps are merged recursively, while lists are combined according
+// to the merge strategy for their key path. Other values are overridden
+func (e *Environment) MergeWithOptions(other *Environment, opts *MergeOptions) (*Environment, error) {
 	copy, err := e.deepCopy()
+	if err != nil {
+		return &copy, err
+	}
 
-	if other != nil && err == nil {
+	if other == nil {
+		return &copy, nil
+	}
+
+	if opts == nil || opts.isReplaceOnly() {
 		if err = mergo.Merge(&copy.Values, other.Values, mergo.WithOverride, mergo.WithOverwriteWithEmptyValue); err != nil {
 			return nil, err
 		}
+		return &copy, nil
+	}
+
+	otherCopy, err := other.deepCopy()
+	if err != nil {
+		return nil, err
+	}
+
+	if copy.Values == nil {
+		copy.Values = map[string]interface{}{}
 	}
-	return &copy, err
+
+	if err = mergeMaps(copy.Values, otherCopy.Values, "", opts); err != nil {
+		return nil, err
+	}
+
+	return &copy, nil
 }
@@ -95,3 +95,302 @@ func TestMerge_OverwriteWithNilValue_Issue1154(t *testing.T) {
 		t.Errorf(diff)
 	}
 }
+
+func TestMerge_AppendLists(t *testing.T) {
+	dst := &Environment{
+		Name: "dst",
+		Values: map[string]interface{}{
+			"rails": map[string]interface{}{
+				"envs": []interface{}{
+					map[string]interface{}{"key": "RAILS_ENV", "value": "production"},
+				},
+			},
+		},
+	}
+
+	src := &Environment{
+		Name: "src",
+		Values: map[string]interface{}{
+			"rails": map[string]interface{}{
+				"envs": []interface{}{
+					map[string]interface{}{"key": "DEBUG", "value": "true"},
+				},
+			},
+		},
+	}
+
+	merged, err := dst.MergeWithOptions(src, &MergeOptions{
+		Default: MergeStrategy{Type: ListMergeAppend},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]interface{}{
+		"rails": map[string]interface{}{
+			"envs": []interface{}{
+				map[string]interface{}{"key": "RAILS_ENV", "value": "production"},
+				map[string]interface{}{"key": "DEBUG", "value": "true"},
+			},
+		},
+	}
+
+	if diff := cmp.Diff(expected, merged.Values); diff != "" {
+		t.Errorf(diff)
+	}
+}
+
+func TestMerge_MergeByKeyNestedListsOfMaps(t *testing.T) {
+	dst := &Environment{
+		Name: "dst",
+		Values: map[string]interface{}{
+			"services": []interface{}{
+				map[string]interface{}{
+					"name":           "web",
+					"instance_count": 1,
+					"envs": []interface{}{
+						map[string]interface{}{"key": "RAILS_ENV", "value": "staging"},
+						map[string]interface{}{"key": "LOG_LEVEL", "value": "info"},
+					},
+				},
+				map[string]interface{}{
+					"name": "worker",
+				},
+			},
+		},
+	}
+
+	src := &Environment{
+		Name: "src",
+		Values: map[string]interface{}{
+			"services": []interface{}{
+				map[string]interface{}{
+					"name":           "web",
+					"instance_count": 3,
+					"envs": []interface{}{
+						map[string]interface{}{"key": "RAILS_ENV", "value": "production"},
+						map[string]interface{}{"key": "CDN_HOST", "value": "cdn.example.com"},
+					},
+				},
+				map[string]interface{}{
+					"name": "scheduler",
+				},
+			},
+		},
+	}
+
+	merged, err := dst.MergeWithOptions(src, &MergeOptions{
+		Keys: map[string]MergeStrategy{
+			"services":      {Type: ListMergeByKey, Key: "name"},
+			"services.envs": {Type: ListMergeByKey, Key: "key"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]interface{}{
+		"services": []interface{}{
+			map[string]interface{}{
+				"name":           "web",
+				"instance_count": uint64(3),
+				"envs": []interface{}{
+					map[string]interface{}{"key": "RAILS_ENV", "value": "production"},
+					map[string]interface{}{"key": "LOG_LEVEL", "value": "info"},
+					map[string]interface{}{"key": "CDN_HOST", "value": "cdn.example.com"},
+				},
+			},
+			map[string]interface{}{
+				"name": "worker",
+			},
+			map[string]interface{}{
+				"name": "scheduler",
+			},
+		},
+	}
+
+	if diff := cmp.Diff(expected, merged.Values); diff != "" {
+		t.Errorf(diff)
+	}
+}
+
+func TestMerge_PerKeyStrategyOverridesDefault(t *testing.T) {
+	dst := &Environment{
+		Name: "dst",
+		Values: map[string]interface{}{
+			"domains": []interface{}{"a.example.com"},
+			"regions": []interface{}{"nyc"},
+		},
+	}
+
+	src := &Environment{
+		Name: "src",
+		Values: map[string]interface{}{
+			"domains": []interface{}{"b.example.com"},
+			"regions": []interface{}{"ams"},
+		},
+	}
+
+	merged, err := dst.MergeWithOptions(src, &MergeOptions{
+		Default: MergeStrategy{Type: ListMergeAppend},
+		Keys: map[string]MergeStrategy{
+			"regions": {Type: ListMergeReplace},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]interface{}{
+		"domains": []interface{}{"a.example.com", "b.example.com"},
+		"regions": []interface{}{"ams"},
+	}
+
+	if diff := cmp.Diff(expected, merged.Values); diff != "" {
+		t.Errorf(diff)
+	}
+}
+
+func TestMerge_MergeByKeyRequiresMaps(t *testing.T) {
+	dst := &Environment{
+		Name:   "dst",
+		Values: map[string]interface{}{"domains": []interface{}{"a.example.com"}},
+	}
+
+	src := &Environment{
+		Name:   "src",
+		Values: map[string]interface{}{"domains": []interface{}{"b.example.com"}},
+	}
+
+	_, err := dst.MergeWithOptions(src, &MergeOptions{
+		Default: MergeStrategy{Type: ListMergeByKey, Key: "name"},
+	})
+
+	if err == nil || err.Error() != "Cannot merge list domains by key name: item b.example.com is not a map" {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestParseMergeStrategy(t *testing.T) {
+	valid := map[string]MergeStrategy{
+		"replace":          {Type: ListMergeReplace},
+		"append":           {Type: ListMergeAppend},
+		"merge-by-key:key": {Type: ListMergeByKey, Key: "key"},
+	}
+
+	for value, expected := range valid {
+		strategy, err := ParseMergeStrategy(value)
+		if err != nil {
+			t.Errorf("unexpected error for %s: %v", value, err)
+		}
+
+		if strategy != expected {
+			t.Errorf("unexpected strategy for %s: expected=%v, got=%v", value, expected, strategy)
+		}
+	}
+
+	for _, value := range []string{"merge", "merge-by-key", "append:name"} {
+		if _, err := ParseMergeStrategy(value); err == nil {
+			t.Errorf("expected error for %s", value)
+		}
+	}
+}
+
+func TestFromVars(t *testing.T) {
+	vars := []string{
+		"PATH=/usr/bin",
+		"APPFILE_VALUES_rails__instance_count=3",
+		"APPFILE_VALUES_rails__debug=true",
+		"APPFILE_VALUES_ratio=0.5",
+		"APPFILE_VALUES_name=my-app",
+		"APPFILE_VALUES_version=1.2.3",
+	}
+
+	actual, overridden, err := FromVars("review", vars, DefaultValuesPrefix, DefaultValuesSeparator)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]interface{}{
+		"rails": map[string]interface{}{
+			"instance_count": int64(3),
+			"debug":          true,
+		},
+		"ratio":   0.5,
+		"name":    "my-app",
+		"version": "1.2.3",
+	}
+	if diff := cmp.Diff(expected, actual.Values); diff != "" {
+		t.Errorf(diff)
+	}
+
+	expectedOverridden := []VarValue{
+		{Var: "APPFILE_VALUES_name", Key: "name"},
+		{Var: "APPFILE_VALUES_rails__debug", Key: "rails.debug"},
+		{Var: "APPFILE_VALUES_rails__instance_count", Key: "rails.instance_count"},
+		{Var: "APPFILE_VALUES_ratio", Key: "ratio"},
+		{Var: "APPFILE_VALUES_version", Key: "version"},
+	}
+	if diff := cmp.Diff(expectedOverridden, overridden); diff != "" {
+		t.Errorf(diff)
+	}
+}
+
+func TestFromVars_CustomPrefixAndSeparator(t *testing.T) {
+	vars := []string{
+		"CI_rails.instance_count=2",
+		"APPFILE_VALUES_name=ignored",
+	}
+
+	actual, _, err := FromVars("review", vars, "CI_", ".")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]interface{}{
+		"rails": map[string]interface{}{
+			"instance_count": int64(2),
+		},
+	}
+	if diff := cmp.Diff(expected, actual.Values); diff != "" {
+		t.Errorf(diff)
+	}
+}
+
+func TestFromVars_KeepsNonCanonicalNumbers(t *testing.T) {
+	vars := []string{
+		"APPFILE_VALUES_version=1.10",
+		"APPFILE_VALUES_zip=007",
+		"APPFILE_VALUES_scientific=1e3",
+		"APPFILE_VALUES_negative=-2",
+		"APPFILE_VALUES_ratio=1.25",
+	}
+
+	actual, _, err := FromVars("review", vars, DefaultValuesPrefix, DefaultValuesSeparator)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]interface{}{
+		"version":    "1.10",
+		"zip":        "007",
+		"scientific": "1e3",
+		"negative":   int64(-2),
+		"ratio":      1.25,
+	}
+	if diff := cmp.Diff(expected, actual.Values); diff != "" {
+		t.Errorf(diff)
+	}
+}
+
+func TestFromVars_ConflictingKeys(t *testing.T) {
+	vars := []string{
+		"APPFILE_VALUES_rails=on",
+		"APPFILE_VALUES_rails__instance_count=2",
+	}
+
+	_, _, err := FromVars("review", vars, DefaultValuesPrefix, DefaultValuesSeparator)
+	if err == nil {
+		t.Fatal("expected error when a key is used both as a value and as a map")
+	}
+}
@@ -0,0 +1,195 @@
+package env
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// ListMergeType is the way lists are combined when merging environment values
+type ListMergeType string
+
+const (
+	// ListMergeReplace replaces the list with the later one
+	ListMergeReplace ListMergeType = "replace"
+	// ListMergeAppend appends the items of the later list
+	ListMergeAppend ListMergeType = "append"
+	// ListMergeByKey merges the items sharing the same value for a key and appends the rest
+	ListMergeByKey ListMergeType = "merge-by-key"
+)
+
+// MergeStrategy describes how to combine two lists
+type MergeStrategy struct {
+	Type ListMergeType
+	// Key is the field identifying the items of the list for ListMergeByKey
+	Key string
+}
+
+// ParseMergeStrategy parses strategies written as replace, append or merge-by-key:<key>
+func ParseMergeStrategy(value string) (MergeStrategy, error) {
+	parts := strings.SplitN(value, ":", 2)
+
+	switch ListMergeType(parts[0]) {
+	case ListMergeReplace, ListMergeAppend:
+		if len(parts) == 1 {
+			return MergeStrategy{Type: ListMergeType(parts[0])}, nil
+		}
+	case ListMergeByKey:
+		if len(parts) == 2 && parts[1] != "" {
+			return MergeStrategy{Type: ListMergeByKey, Key: parts[1]}, nil
+		}
+
+		return MergeStrategy{}, fmt.Errorf("Merge strategy %s must specify the key, e.g merge-by-key:name", value)
+	}
+
+	return MergeStrategy{}, fmt.Errorf("Unknown merge strategy %s. Must be one of replace, append or merge-by-key:<key>", value)
+}
+
+func (strategy MergeStrategy) String() string {
+	if strategy.Type == ListMergeByKey {
+		return fmt.Sprintf("%s:%s", strategy.Type, strategy.Key)
+	}
+
+	return string(strategy.Type)
+}
+
+// MergeOptions configures the merge strategies for lists
+type MergeOptions struct {
+	// Default is the strategy for lists without a specific strategy
+	Default MergeStrategy
+	// Keys maps the dot separated path of a list to its strategy. The path
+	// of a list nested inside the items of another list omits the index,
+	// e.g services.envs
+	Keys map[string]MergeStrategy
+}
+
+func (opts *MergeOptions) strategyFor(path string) MergeStrategy {
+	if strategy, ok := opts.Keys[path]; ok {
+		return strategy
+	}
+
+	if opts.Default.Type == "" {
+		return MergeStrategy{Type: ListMergeReplace}
+	}
+
+	return opts.Default
+}
+
+func (opts *MergeOptions) isReplaceOnly() bool {
+	if opts.Default.Type != "" && opts.Default.Type != ListMergeReplace {
+		return false
+	}
+
+	for _, strategy := range opts.Keys {
+		if strategy.Type != ListMergeReplace {
+			return false
+		}
+	}
+
+	return true
+}
+
+func joinPath(path string, key string) string {
+	if path == "" {
+		return key
+	}
+
+	return path + "." + key
+}
+
+func mergeMaps(dst map[string]interface{}, src map[string]interface{}, path string, opts *MergeOptions) error {
+	for key, srcValue := range src {
+		keyPath := joinPath(path, key)
+		dstValue, ok := dst[key]
+		if !ok {
+			dst[key] = srcValue
+			continue
+		}
+
+		merged, err := mergeValues(dstValue, srcValue, keyPath, opts)
+		if err != nil {
+			return err
+		}
+		dst[key] = merged
+	}
+
+	return nil
+}
+
+func mergeValues(dst interface{}, src interface{}, path string, opts *MergeOptions) (interface{}, error) {
+	switch srcTyped := src.(type) {
+	case map[string]interface{}:
+		dstTyped, ok := dst.(map[string]interface{})
+		if !ok {
+			return src, nil
+		}
+
+		if err := mergeMaps(dstTyped, srcTyped, path, opts); err != nil {
+			return nil, err
+		}
+		return dstTyped, nil
+	case []interface{}:
+		dstTyped, ok := dst.([]interface{})
+		if !ok {
+			return src, nil
+		}
+
+		return mergeLists(dstTyped, srcTyped, path, opts)
+	default:
+		return src, nil
+	}
+}
+
+func mergeLists(dst []interface{}, src []interface{}, path string, opts *MergeOptions) ([]interface{}, error) {
+	strategy := opts.strategyFor(path)
+
+	switch strategy.Type {
+	case ListMergeAppend:
+		return append(dst, src...), nil
+	case ListMergeByKey:
+		for _, srcItem := range src {
+			index, err := indexByKey(dst, srcItem, strategy.Key, path)
+			if err != nil {
+				return nil, err
+			}
+
+			if index < 0 {
+				dst = append(dst, srcItem)
+				continue
+			}
+
+			merged, err := mergeValues(dst[index], srcItem, path, opts)
+			if err != nil {
+				return nil, err
+			}
+			dst[index] = merged
+		}
+
+		return dst, nil
+	default:
+		return src, nil
+	}
+}
+
+// indexByKey returns the index of the item in list with the same value for key
+// as item, or -1 if there is none or item doesn't have the key
+func indexByKey(list []interface{}, item interface{}, key string, path string) (int, error) {
+	itemMap, ok := item.(map[string]interface{})
+	if !ok {
+		return -1, fmt.Errorf("Cannot merge list %s by key %s: item %v is not a map", path, key, item)
+	}
+
+	value, ok := itemMap[key]
+	if !ok {
+		return -1, nil
+	}
+
+	for i, candidate := range list {
+		candidateMap, ok := candidate.(map[string]interface{})
+		if ok && reflect.DeepEqual(candidateMap[key], value) {
+			return i, nil
+		}
+	}
+
+	return -1, nil
+}
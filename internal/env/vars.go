@@ -0,0 +1,88 @@
+package env
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/renehernandez/appfile/internal/maputil"
+)
+
+const (
+	// DefaultValuesPrefix is the prefix of the environment variables holding values
+	DefaultValuesPrefix = "APPFILE_VALUES_"
+	// DefaultValuesSeparator separates the nested keys in the environment variable names
+	DefaultValuesSeparator = "__"
+)
+
+// VarValue is a value read from an environment variable
+type VarValue struct {
+	// Var is the name of the environment variable
+	Var string
+	// Key is the dot separated path of the value
+	Key string
+}
+
+// FromVars returns an environment with the values declared by the variables,
+// written as KEY=VALUE, starting with prefix. The rest of the variable name is
+// the path of the value, with nested keys split by separator. Numbers and
+// booleans are converted, any other value is kept as a string
+func FromVars(name string, vars []string, prefix string, separator string) (*Environment, []VarValue, error) {
+	values := map[string]interface{}{}
+	overridden := []VarValue{}
+
+	sorted := append([]string{}, vars...)
+	sort.Strings(sorted)
+
+	for _, variable := range sorted {
+		parts := strings.SplitN(variable, "=", 2)
+		if len(parts) != 2 || !strings.HasPrefix(parts[0], prefix) {
+			continue
+		}
+
+		key := strings.TrimPrefix(parts[0], prefix)
+		path := strings.Split(key, separator)
+		for _, part := range path {
+			if part == "" {
+				return nil, []VarValue{}, fmt.Errorf("Environment variable %s has an empty key", parts[0])
+			}
+		}
+
+		if err := maputil.Set(values, path, inferType(parts[1])); err != nil {
+			return nil, []VarValue{}, fmt.Errorf("Cannot set value from environment variable %s: %s", parts[0], err)
+		}
+
+		overridden = append(overridden, VarValue{
+			Var: parts[0],
+			Key: strings.Join(path, "."),
+		})
+	}
+
+	return &Environment{
+		Name:   name,
+		Values: values,
+	}, overridden, nil
+}
+
+func inferType(value string) interface{} {
+	switch value {
+	case "true":
+		return true
+	case "false":
+		return false
+	}
+
+	// Numbers are only converted when they are written in their canonical
+	// form, so that values such as 007 or 1.10 reach the spec as written
+	if number, err := strconv.ParseInt(value, 10, 64); err == nil && strconv.FormatInt(number, 10) == value {
+		return number
+	}
+
+	if number, err := strconv.ParseFloat(value, 64); err == nil && !math.IsInf(number, 0) && !math.IsNaN(number) && strconv.FormatFloat(number, 'f', -1, 64) == value {
+		return number
+	}
+
+	return value
+}
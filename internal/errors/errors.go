@@ -1,25 +1,127 @@
+// Package errors classifies the errors reported by appfile, so that callers
+// can tell them apart and the CLI can exit with a distinct code for each kind
 package errors
 
 import (
-	"github.com/pkg/errors"
+	"errors"
+	"fmt"
+)
+
+// Kind is the class of an error
+type Kind int
+
+const (
+	// KindUnknown is the kind of the errors that were not classified
+	KindUnknown Kind = iota
+	// KindConfig reports invalid options, templates or files that cannot be read or parsed
+	KindConfig
+	// KindValidation reports specs or values that don't pass validation
+	KindValidation
+	// KindAuth reports missing or rejected access tokens
+	KindAuth
+	// KindAPI reports failed requests to the DigitalOcean API
+	KindAPI
+	// KindDeployment reports apps that could not be synced or destroyed
+	KindDeployment
+	// KindNotFound reports resources missing in DigitalOcean
+	KindNotFound
+	// KindAborted reports operations aborted by the user when asked for approval
+	KindAborted
+)
 
-	"github.com/renehernandez/appfile/internal/log"
+// Exit codes of the CLI for every kind of error
+const (
+	ExitOK         = 0
+	ExitUnknown    = 1
+	ExitConfig     = 2
+	ExitValidation = 3
+	ExitAuth       = 4
+	ExitAPI        = 5
+	ExitDeployment = 6
+	ExitNotFound   = 7
+	ExitAborted    = 8
 )
 
-func CheckAndFailf(err error, msg string, args ...interface{}) {
-	if err != nil {
-		log.Fatalln(errors.Wrapf(err, msg, args...))
+var kindNames = map[Kind]string{
+	KindUnknown:    "unknown",
+	KindConfig:     "config",
+	KindValidation: "validation",
+	KindAuth:       "auth",
+	KindAPI:        "api",
+	KindDeployment: "deployment",
+	KindNotFound:   "not found",
+	KindAborted:    "aborted",
+}
+
+var exitCodes = map[Kind]int{
+	KindUnknown:    ExitUnknown,
+	KindConfig:     ExitConfig,
+	KindValidation: ExitValidation,
+	KindAuth:       ExitAuth,
+	KindAPI:        ExitAPI,
+	KindDeployment: ExitDeployment,
+	KindNotFound:   ExitNotFound,
+	KindAborted:    ExitAborted,
+}
+
+func (kind Kind) String() string {
+	return kindNames[kind]
+}
+
+// Error is an error of a known kind
+type Error struct {
+	Kind Kind
+	Err  error
+}
+
+func (err *Error) Error() string {
+	return err.Err.Error()
+}
+
+// Unwrap returns the classified error
+func (err *Error) Unwrap() error {
+	return err.Err
+}
+
+// New returns an error of the given kind with the formatted message
+func New(kind Kind, format string, args ...interface{}) error {
+	return &Error{Kind: kind, Err: fmt.Errorf(format, args...)}
+}
+
+// Wrap marks err with the given kind. It returns nil if err is nil
+func Wrap(kind Kind, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	return &Error{Kind: kind, Err: err}
+}
+
+// Classify marks err with the given kind, unless it has already been classified.
+// It returns nil if err is nil
+func Classify(kind Kind, err error) error {
+	if KindOf(err) != KindUnknown {
+		return err
 	}
+
+	return Wrap(kind, err)
 }
 
-func CheckAndFailln(err error, msg string) {
-	if err != nil {
-		log.Fatalln(errors.Wrap(err, msg))
+// KindOf returns the kind of the outermost classified error in the chain of err
+func KindOf(err error) Kind {
+	var classified *Error
+	if errors.As(err, &classified) {
+		return classified.Kind
 	}
+
+	return KindUnknown
 }
 
-func CheckAndFail(err error) {
-	if err != nil {
-		log.Fatalln(err)
+// ExitCode returns the exit code of the CLI for err
+func ExitCode(err error) int {
+	if err == nil {
+		return ExitOK
 	}
+
+	return exitCodes[KindOf(err)]
 }
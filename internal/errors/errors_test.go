@@ -0,0 +1,49 @@
+package errors
+
+import (
+	"fmt"
+	"testing"
+
+	pkgerrors "github.com/pkg/errors"
+	"github.com/stretchr/testify/suite"
+)
+
+type ErrorsSuite struct {
+	suite.Suite
+}
+
+func (suite *ErrorsSuite) TestKindOfWrappedErrors() {
+	err := New(KindAuth, "Token file %s is empty", "token")
+	wrapped := pkgerrors.Wrap(err, "Could not configure access to app web")
+
+	suite.Equal(KindAuth, KindOf(wrapped))
+	suite.Equal("Could not configure access to app web: Token file token is empty", wrapped.Error())
+	suite.Equal(KindUnknown, KindOf(fmt.Errorf("unclassified")))
+}
+
+func (suite *ErrorsSuite) TestClassifyKeepsExistingKind() {
+	err := Classify(KindConfig, New(KindValidation, "invalid"))
+	suite.Equal(KindValidation, KindOf(err))
+
+	err = Classify(KindConfig, fmt.Errorf("missing file"))
+	suite.Equal(KindConfig, KindOf(err))
+
+	suite.Nil(Classify(KindConfig, nil))
+	suite.Nil(Wrap(KindConfig, nil))
+}
+
+func (suite *ErrorsSuite) TestExitCode() {
+	suite.Equal(ExitOK, ExitCode(nil))
+	suite.Equal(ExitUnknown, ExitCode(fmt.Errorf("unclassified")))
+	suite.Equal(ExitConfig, ExitCode(New(KindConfig, "config")))
+	suite.Equal(ExitValidation, ExitCode(New(KindValidation, "validation")))
+	suite.Equal(ExitAuth, ExitCode(New(KindAuth, "auth")))
+	suite.Equal(ExitAPI, ExitCode(New(KindAPI, "api")))
+	suite.Equal(ExitDeployment, ExitCode(New(KindDeployment, "deployment")))
+	suite.Equal(ExitNotFound, ExitCode(New(KindNotFound, "not found")))
+	suite.Equal(ExitAborted, ExitCode(New(KindAborted, "aborted")))
+}
+
+func TestErrorsSuite(t *testing.T) {
+	suite.Run(t, &ErrorsSuite{})
+}
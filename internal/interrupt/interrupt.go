@@ -0,0 +1,88 @@
+// Package interrupt handles Ctrl-C while apps are being changed. The first
+// interrupt stops new operations from starting, letting the in-flight ones
+// finish, while the second one cancels them
+package interrupt
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+
+	"github.com/renehernandez/appfile/internal/log"
+)
+
+type stopKey struct{}
+
+type stopper struct {
+	once sync.Once
+	done chan struct{}
+}
+
+func (s *stopper) stop() {
+	s.once.Do(func() {
+		close(s.done)
+	})
+}
+
+// WithStop returns a copy of parent that can be asked to stop starting new operations
+// through the returned function, without cancelling the in-flight ones
+func WithStop(parent context.Context) (context.Context, func()) {
+	s := &stopper{done: make(chan struct{})}
+
+	return context.WithValue(parent, stopKey{}, s), s.stop
+}
+
+// Notify returns a copy of parent handling SIGINT and SIGTERM. The first
+// signal stops new operations and the second one cancels the context.
+// The returned function releases the signal handler
+func Notify(parent context.Context) (context.Context, func()) {
+	ctx, cancel := context.WithCancel(parent)
+	ctx, stop := WithStop(ctx)
+
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+
+	go func() {
+		select {
+		case <-signals:
+			log.Warningln("Interrupted, waiting for in-flight operations to finish. Interrupt again to cancel them")
+			stop()
+		case <-ctx.Done():
+			return
+		}
+
+		select {
+		case <-signals:
+			log.Warningln("Interrupted again, cancelling in-flight operations")
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	return ctx, func() {
+		signal.Stop(signals)
+		cancel()
+	}
+}
+
+// Stopped reports whether new operations should not be started, either
+// because they were asked to stop or the context is done
+func Stopped(ctx context.Context) bool {
+	if ctx.Err() != nil {
+		return true
+	}
+
+	s, ok := ctx.Value(stopKey{}).(*stopper)
+	if !ok {
+		return false
+	}
+
+	select {
+	case <-s.done:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,62 @@
+package log
+
+import (
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+// Fields are the structured fields attached to the messages of a Logger
+type Fields map[string]interface{}
+
+// Logger prints messages carrying structured fields. JSON logs keep the
+// fields as separate keys, while console logs print them after the message
+type Logger struct {
+	fields Fields
+}
+
+// With returns a Logger attaching the given fields to every message
+func With(fields Fields) *Logger {
+	return &Logger{fields: fields}
+}
+
+// With returns a Logger attaching the given fields on top of the existing ones
+func (logger *Logger) With(fields Fields) *Logger {
+	merged := Fields{}
+	for key, value := range logger.fields {
+		merged[key] = value
+	}
+	for key, value := range fields {
+		merged[key] = value
+	}
+
+	return &Logger{fields: merged}
+}
+
+// Debugf prints a debug level msg
+func (logger *Logger) Debugf(format string, a ...interface{}) {
+	logger.event(log.Debug()).Msgf(format, a...)
+}
+
+// Infof prints an information level msg
+func (logger *Logger) Infof(format string, a ...interface{}) {
+	logger.event(log.Info()).Msgf(format, a...)
+}
+
+// Warningf prints a warning level msg
+func (logger *Logger) Warningf(format string, a ...interface{}) {
+	logger.event(log.Warn()).Msgf(format, a...)
+}
+
+// Errorf prints an error level msg
+func (logger *Logger) Errorf(format string, a ...interface{}) {
+	logger.event(log.Error()).Msgf(format, a...)
+}
+
+// Resultf prints the result of a command, even in quiet mode
+func (logger *Logger) Resultf(format string, a ...interface{}) {
+	logger.event(log.Log().Str(zerolog.LevelFieldName, resultLevel)).Msgf(format, a...)
+}
+
+func (logger *Logger) event(event *zerolog.Event) *zerolog.Event {
+	return event.Fields(map[string]interface{}(logger.fields))
+}
@@ -3,9 +3,12 @@ package log
 
 import (
 	"fmt"
+	"io"
 	"os"
+	"time"
 
 	"github.com/fatih/color"
+	"github.com/pkg/errors"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 )
@@ -17,12 +20,113 @@ var (
 	red    = color.New(color.FgRed, color.Bold)
 )
 
+// Output formats of the logs
+const (
+	FormatConsole = "console"
+	FormatJSON    = "json"
+)
+
+// Names of the structured fields attached to the logs
+const (
+	FieldApp          = "app"
+	FieldEnvironment  = "environment"
+	FieldComponent    = "component"
+	FieldDeploymentID = "deployment_id"
+	FieldOperation    = "operation"
+)
+
+// resultLevel is the level of the results, printed even in quiet mode
+const resultLevel = "result"
+
+// Options configures the log output
+type Options struct {
+	// Level is the minimum level of the logs: debug, info, warn or error
+	Level string
+	// Format is the output format of the logs: console or json
+	Format string
+	// File receives the logs instead of the standard output when set
+	File string
+	// Quiet suppresses everything except errors and results
+	Quiet bool
+	// Stderr sends the logs to the standard error instead of the standard output
+	Stderr bool
+}
+
+// logFile is the file opened by the last call to Initialize
+var logFile *os.File
+
 // Initialize Initializes logging configuration
-func Initialize(logLevel string) {
-	consoleWriter := zerolog.ConsoleWriter{Out: os.Stdout}
+func Initialize(opts Options) error {
+	level, err := parseLevel(opts.Level)
+	if err != nil {
+		return err
+	}
+	if opts.Quiet {
+		level = zerolog.ErrorLevel
+	}
+
+	var out io.Writer = os.Stdout
+	if opts.Stderr {
+		out = os.Stderr
+	}
+	if opts.File != "" {
+		file, err := os.OpenFile(opts.File, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			return errors.Wrapf(err, "Failed to open log file %s", opts.File)
+		}
+		if logFile != nil {
+			logFile.Close()
+		}
+		logFile = file
+		out = file
+	}
+
+	switch opts.Format {
+	case FormatConsole, "":
+		log.Logger = zerolog.New(consoleWriter(out, opts.File != "")).With().Timestamp().Logger()
+	case FormatJSON:
+		log.Logger = zerolog.New(out).With().Timestamp().Logger()
+	default:
+		return errors.Errorf("Unknown log format %s, expected %s or %s", opts.Format, FormatConsole, FormatJSON)
+	}
+
+	zerolog.SetGlobalLevel(level)
+
+	Debugln("Logger has been configured")
+
+	return nil
+}
+
+func parseLevel(logLevel string) (zerolog.Level, error) {
+	switch logLevel {
+	case "debug":
+		return zerolog.DebugLevel, nil
+	case "info":
+		return zerolog.InfoLevel, nil
+	case "warn":
+		return zerolog.WarnLevel, nil
+	case "error":
+		return zerolog.ErrorLevel, nil
+	}
+
+	return zerolog.NoLevel, errors.Errorf("Unknown log level %s", logLevel)
+}
+
+// consoleWriter returns the human readable writer. Timestamps are only
+// printed to files, and colors only to the terminal
+func consoleWriter(out io.Writer, toFile bool) zerolog.ConsoleWriter {
+	consoleWriter := zerolog.ConsoleWriter{Out: out, NoColor: toFile, TimeFormat: time.RFC3339}
 
-	consoleWriter.FormatTimestamp = func(i interface{}) string {
-		return ""
+	if !toFile {
+		consoleWriter.FormatTimestamp = func(i interface{}) string {
+			return ""
+		}
+	}
+	prefix := prefix
+	if toFile {
+		prefix = func(c *color.Color, p string) string {
+			return p + ":"
+		}
 	}
 	consoleWriter.FormatLevel = func(i interface{}) string {
 		var l string
@@ -44,24 +148,7 @@ func Initialize(logLevel string) {
 		return l
 	}
 
-	multi := zerolog.MultiLevelWriter(consoleWriter)
-
-	log.Logger = zerolog.New(multi)
-
-	switch logLevel {
-	case "debug":
-		zerolog.SetGlobalLevel(zerolog.DebugLevel)
-	case "info":
-		zerolog.SetGlobalLevel(zerolog.InfoLevel)
-	case "warn":
-		zerolog.SetGlobalLevel(zerolog.WarnLevel)
-	case "error":
-		zerolog.SetGlobalLevel(zerolog.ErrorLevel)
-	default:
-		Fatalf("Unknown log level %s", logLevel)
-	}
-
-	Debugln("Logger has been configured")
+	return consoleWriter
 }
 
 // Level returns the current level of the logger
@@ -69,6 +156,11 @@ func Level() string {
 	return zerolog.GlobalLevel().String()
 }
 
+// Resultf prints the result of a command, even in quiet mode
+func Resultf(format string, a ...interface{}) {
+	log.Log().Str(zerolog.LevelFieldName, resultLevel).Msgf(format, a...)
+}
+
 func prefix(c *color.Color, p string) string {
 	return c.SprintfFunc()(p + ":")
 }
@@ -0,0 +1,83 @@
+package log
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type LogSuite struct {
+	suite.Suite
+
+	file string
+}
+
+func (suite *LogSuite) SetupTest() {
+	dir, err := ioutil.TempDir("", "log-")
+	suite.Require().NoError(err)
+	suite.file = filepath.Join(dir, "appfile.log")
+}
+
+func (suite *LogSuite) TearDownTest() {
+	os.RemoveAll(filepath.Dir(suite.file))
+}
+
+func (suite *LogSuite) TestJSONLogsCarryFields() {
+	suite.Require().NoError(Initialize(Options{Level: "info", Format: FormatJSON, File: suite.file}))
+
+	With(Fields{FieldApp: "web", FieldOperation: "create"}).
+		With(Fields{FieldDeploymentID: "deployment-1"}).
+		Infof("App synced successfully")
+
+	entries := suite.readEntries()
+	suite.Require().Len(entries, 1)
+	suite.Equal("info", entries[0]["level"])
+	suite.Equal("App synced successfully", entries[0]["message"])
+	suite.Equal("web", entries[0][FieldApp])
+	suite.Equal("create", entries[0][FieldOperation])
+	suite.Equal("deployment-1", entries[0][FieldDeploymentID])
+}
+
+func (suite *LogSuite) TestQuietOnlyPrintsErrorsAndResults() {
+	suite.Require().NoError(Initialize(Options{Level: "debug", Format: FormatJSON, File: suite.file, Quiet: true}))
+
+	Debugf("debug")
+	Infof("info")
+	Warningf("warning")
+	Errorf("error")
+	Resultf("result")
+
+	levels := []string{}
+	for _, entry := range suite.readEntries() {
+		levels = append(levels, entry["level"].(string))
+	}
+	suite.Equal([]string{"error", "result"}, levels)
+}
+
+func (suite *LogSuite) TestInvalidOptions() {
+	suite.EqualError(Initialize(Options{Level: "info", Format: "xml"}), "Unknown log format xml, expected console or json")
+	suite.EqualError(Initialize(Options{Level: "verbose"}), "Unknown log level verbose")
+}
+
+func (suite *LogSuite) readEntries() []map[string]interface{} {
+	content, err := ioutil.ReadFile(suite.file)
+	suite.Require().NoError(err)
+
+	entries := []map[string]interface{}{}
+	for _, line := range strings.Split(strings.TrimSpace(string(content)), "\n") {
+		entry := map[string]interface{}{}
+		suite.Require().NoError(json.Unmarshal([]byte(line), &entry))
+		entries = append(entries, entry)
+	}
+
+	return entries
+}
+
+func TestLogSuite(t *testing.T) {
+	suite.Run(t, &LogSuite{})
+}
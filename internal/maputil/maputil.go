@@ -66,29 +66,30 @@ func recursivelyStringifyMapKey(v interface{}) (interface{}, error) {
 }
 
 type arg interface {
-	getMap(map[string]interface{}) map[string]interface{}
-	set(map[string]interface{}, string)
+	getMap(map[string]interface{}) (map[string]interface{}, error)
+	set(map[string]interface{}, interface{}) error
 }
 
 type keyArg struct {
 	key string
 }
 
-func (a keyArg) getMap(m map[string]interface{}) map[string]interface{} {
+func (a keyArg) getMap(m map[string]interface{}) (map[string]interface{}, error) {
 	_, ok := m[a.key]
 	if !ok {
 		m[a.key] = map[string]interface{}{}
 	}
 	switch t := m[a.key].(type) {
 	case map[string]interface{}:
-		return t
+		return t, nil
 	default:
-		panic(fmt.Errorf("unexpected type: %v(%T)", t, t))
+		return nil, fmt.Errorf("unexpected type: %v(%T)", t, t)
 	}
 }
 
-func (a keyArg) set(m map[string]interface{}, value string) {
+func (a keyArg) set(m map[string]interface{}, value interface{}) error {
 	m[a.key] = value
+	return nil
 }
 
 type indexedKeyArg struct {
@@ -96,7 +97,7 @@ type indexedKeyArg struct {
 	index int
 }
 
-func (a indexedKeyArg) getArray(m map[string]interface{}) []interface{} {
+func (a indexedKeyArg) getArray(m map[string]interface{}) ([]interface{}, error) {
 	_, ok := m[a.key]
 	if !ok {
 		m[a.key] = make([]interface{}, a.index+1)
@@ -108,29 +109,36 @@ func (a indexedKeyArg) getArray(m map[string]interface{}) []interface{} {
 			copy(t, t2)
 			t = t2
 		}
-		return t
+		return t, nil
 	default:
-		panic(fmt.Errorf("unexpected type: %v(%T)", t, t))
+		return nil, fmt.Errorf("unexpected type: %v(%T)", t, t)
 	}
 }
 
-func (a indexedKeyArg) getMap(m map[string]interface{}) map[string]interface{} {
-	t := a.getArray(m)
+func (a indexedKeyArg) getMap(m map[string]interface{}) (map[string]interface{}, error) {
+	t, err := a.getArray(m)
+	if err != nil {
+		return nil, err
+	}
 	if t[a.index] == nil {
 		t[a.index] = map[string]interface{}{}
 	}
 	switch t := t[a.index].(type) {
 	case map[string]interface{}:
-		return t
+		return t, nil
 	default:
-		panic(fmt.Errorf("unexpected type: %v(%T)", t, t))
+		return nil, fmt.Errorf("unexpected type: %v(%T)", t, t)
 	}
 }
 
-func (a indexedKeyArg) set(m map[string]interface{}, value string) {
-	t := a.getArray(m)
+func (a indexedKeyArg) set(m map[string]interface{}, value interface{}) error {
+	t, err := a.getArray(m)
+	if err != nil {
+		return err
+	}
 	t[a.index] = value
 	m[a.key] = t
+	return nil
 }
 
 func getCursor(key string) arg {
@@ -180,14 +188,20 @@ func ParseKey(key string) []string {
 	return r
 }
 
-func Set(m map[string]interface{}, key []string, value string) {
+// Set sets the value at the path of keys in m, creating the intermediate maps.
+// It fails if an intermediate key already holds a value that is not a map
+func Set(m map[string]interface{}, key []string, value interface{}) error {
 	if len(key) == 0 {
-		panic(fmt.Errorf("bug: unexpected length of key: %d", len(key)))
+		return fmt.Errorf("unexpected length of key: %d", len(key))
 	}
 
 	for len(key) > 1 {
-		m, key = getCursor(key[0]).getMap(m), key[1:]
+		next, err := getCursor(key[0]).getMap(m)
+		if err != nil {
+			return err
+		}
+		m, key = next, key[1:]
 	}
 
-	getCursor(key[0]).set(m, value)
+	return getCursor(key[0]).set(m, value)
 }
@@ -67,7 +67,9 @@ func TestMapUtil_KeyArg(t *testing.T) {
 
 	key := []string{"a", "b", "c"}
 
-	Set(m, key, "C")
+	if err := Set(m, key, "C"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
 
 	c := (((m["a"].(map[string]interface{}))["b"]).(map[string]interface{}))["c"]
 
@@ -81,7 +83,9 @@ func TestMapUtil_IndexedKeyArg(t *testing.T) {
 
 	key := []string{"a", "b[0]", "c"}
 
-	Set(m, key, "C")
+	if err := Set(m, key, "C"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
 
 	c := (((m["a"].(map[string]interface{}))["b"].([]interface{}))[0].(map[string]interface{}))["c"]
 
@@ -90,6 +94,23 @@ func TestMapUtil_IndexedKeyArg(t *testing.T) {
 	}
 }
 
+func TestMapUtil_SetFailsOnNonMapKey(t *testing.T) {
+	m := map[string]interface{}{"a": "A"}
+
+	err := Set(m, []string{"a", "b"}, "B")
+	if err == nil {
+		t.Fatal("expected error when an intermediate key is not a map")
+	}
+
+	if m["a"] != "A" {
+		t.Errorf("unexpected a: expected=A, got=%v", m["a"])
+	}
+
+	if err := Set(m, []string{}, "B"); err == nil {
+		t.Fatal("expected error for an empty key")
+	}
+}
+
 type parseKeyTc struct {
 	key    string
 	result map[int]string
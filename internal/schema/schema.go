@@ -0,0 +1,192 @@
+// Package schema holds the JSON schemas of the appfile and app spec files, and validates documents against them
+package schema
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/goccy/go-yaml"
+	"github.com/pkg/errors"
+	"github.com/xeipuuv/gojsonschema"
+)
+
+const (
+	// Appfile is the name of the appfile.yaml schema
+	Appfile = "appfile"
+	// AppSpec is the name of the app spec schema
+	AppSpec = "appspec"
+)
+
+var (
+	//go:embed appfile.schema.json
+	appfileSchema []byte
+
+	//go:embed appspec.schema.json
+	appSpecSchema []byte
+
+	schemas = map[string][]byte{
+		Appfile: appfileSchema,
+		AppSpec: appSpecSchema,
+	}
+)
+
+// Names returns the names of the available schemas
+func Names() []string {
+	names := []string{}
+	for name := range schemas {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// Get returns the JSON schema with the given name
+func Get(name string) ([]byte, error) {
+	schema, ok := schemas[name]
+	if !ok {
+		return []byte{}, fmt.Errorf("Unknown schema %s. Must be one of %s", name, Names())
+	}
+
+	return schema, nil
+}
+
+// Detect returns the name of the schema that matches the yaml document: Appfile
+// when it declares any of the appfile top-level keys, otherwise AppSpec
+func Detect(yamlData []byte) string {
+	var document map[string]interface{}
+	if err := yaml.Unmarshal(yamlData, &document); err != nil {
+		return AppSpec
+	}
+
+	for _, key := range []string{"specs", "appfiles", "environments", "hooks"} {
+		if _, ok := document[key]; ok {
+			return Appfile
+		}
+	}
+
+	return AppSpec
+}
+
+// ValidationError lists every violation found when validating a document against a schema
+type ValidationError struct {
+	Schema     string
+	Violations []string
+}
+
+func (err *ValidationError) Error() string {
+	return fmt.Sprintf("Document does not match %s schema:\n  - %s", err.Schema, strings.Join(err.Violations, "\n  - "))
+}
+
+// Validate validates the yaml document against the schema with the given name
+func Validate(name string, yamlData []byte) error {
+	schema, err := Get(name)
+	if err != nil {
+		return err
+	}
+
+	return ValidateWithSchema(name, schema, yamlData)
+}
+
+// ValidateWithSchema validates the yaml document against a JSON schema, which
+// can be written in either JSON or yaml. Null values in the document are treated as unset
+func ValidateWithSchema(name string, schema []byte, yamlData []byte) error {
+	schemaJSON, err := yaml.YAMLToJSON(schema)
+	if err != nil {
+		return errors.Wrapf(err, "Failed to read %s schema", name)
+	}
+
+	var document interface{}
+	if err = yaml.Unmarshal(yamlData, &document); err != nil {
+		return errors.Wrap(err, "Failed to unmarshal document from yaml")
+	}
+
+	documentJSON, err := json.Marshal(removeNulls(document))
+	if err != nil {
+		return errors.Wrap(err, "Failed to convert document to json")
+	}
+
+	result, err := gojsonschema.Validate(
+		gojsonschema.NewBytesLoader(schemaJSON),
+		gojsonschema.NewBytesLoader(documentJSON),
+	)
+	if err != nil {
+		return errors.Wrapf(err, "Failed to validate document against %s schema", name)
+	}
+
+	if result.Valid() {
+		return nil
+	}
+
+	violations := []string{}
+	for _, resultErr := range result.Errors() {
+		violations = append(violations, fmt.Sprintf("%s: %s", fieldPath(resultErr), resultErr.Description()))
+	}
+
+	return &ValidationError{
+		Schema:     name,
+		Violations: violations,
+	}
+}
+
+// fieldPath formats the location of the error as a path like services[0].envs[1].key.
+// Missing required properties are reported at the path of the property
+func fieldPath(resultErr gojsonschema.ResultError) string {
+	parts := strings.Split(resultErr.Context().String(), ".")
+	if property, ok := resultErr.Details()["property"].(string); ok && resultErr.Type() == "required" {
+		parts = append(parts, property)
+	}
+
+	var path strings.Builder
+
+	for _, part := range parts[1:] {
+		if _, err := strconv.Atoi(part); err == nil {
+			path.WriteString(fmt.Sprintf("[%s]", part))
+			continue
+		}
+
+		if path.Len() > 0 {
+			path.WriteString(".")
+		}
+		path.WriteString(part)
+	}
+
+	if path.Len() == 0 {
+		return "(root)"
+	}
+
+	return path.String()
+}
+
+func removeNulls(value interface{}) interface{} {
+	switch typed := value.(type) {
+	case map[string]interface{}:
+		clean := map[string]interface{}{}
+		for k, v := range typed {
+			if v != nil {
+				clean[k] = removeNulls(v)
+			}
+		}
+		return clean
+	case map[interface{}]interface{}:
+		clean := map[string]interface{}{}
+		for k, v := range typed {
+			if v != nil {
+				clean[fmt.Sprint(k)] = removeNulls(v)
+			}
+		}
+		return clean
+	case []interface{}:
+		clean := []interface{}{}
+		for _, v := range typed {
+			clean = append(clean, removeNulls(v))
+		}
+		return clean
+	default:
+		return typed
+	}
+}
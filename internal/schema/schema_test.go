@@ -0,0 +1,155 @@
+package schema
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/digitalocean/godo"
+	"github.com/stretchr/testify/suite"
+)
+
+type SchemaSuite struct {
+	suite.Suite
+}
+
+func (suite *SchemaSuite) TestDetect() {
+	suite.Equal(Appfile, Detect([]byte("specs:\n- ./app.yaml\n")))
+	suite.Equal(Appfile, Detect([]byte("appfiles:\n- ./team/appfile.yaml\n")))
+	suite.Equal(AppSpec, Detect([]byte("name: sample\n")))
+	suite.Equal(AppSpec, Detect([]byte("")))
+}
+
+func (suite *SchemaSuite) TestValidAppSpec() {
+	content := `name: sample
+region: nyc
+services:
+- name: web
+  github:
+    repo: renehernandez/appfile
+    branch: main
+  registry:
+  envs:
+  - key: RAILS_ENV
+    value: production
+`
+	suite.NoError(Validate(AppSpec, []byte(content)))
+}
+
+func (suite *SchemaSuite) TestInvalidAppSpecReportsPaths() {
+	content := `name: sample
+services:
+- name: web
+  instance_count: "2"
+  envs:
+  - key: RAILS_ENV
+    scope: ALWAYS
+static_sites:
+- name: site
+  instance_size_slug: basic-xxs
+`
+	err := Validate(AppSpec, []byte(content))
+
+	suite.Require().Error(err)
+	validationErr, ok := err.(*ValidationError)
+	suite.Require().True(ok)
+	suite.ElementsMatch([]string{
+		"services[0].instance_count: Invalid type. Expected: integer, given: string",
+		`services[0].envs[0].scope: services.0.envs.0.scope must be one of the following: "RUN_TIME", "BUILD_TIME", "RUN_AND_BUILD_TIME"`,
+		"static_sites[0]: Additional property instance_size_slug is not allowed",
+	}, validationErr.Violations)
+}
+
+func (suite *SchemaSuite) TestValidAppfile() {
+	content := `environments:
+  review:
+  - ./review.yaml
+  production:
+    extends: [review]
+    values:
+    - ./production.yaml
+    - name: production
+specs:
+- ./app.yaml
+- path: ./apps/*/app.yaml
+  environments: [review]
+hooks:
+- events: ["presync"]
+  command: echo
+`
+	suite.NoError(Validate(Appfile, []byte(content)))
+}
+
+func (suite *SchemaSuite) TestInvalidAppfile() {
+	content := `spec:
+- ./app.yaml
+`
+	err := Validate(Appfile, []byte(content))
+
+	suite.Require().Error(err)
+	suite.Contains(err.Error(), "(root): Additional property spec is not allowed")
+}
+
+func (suite *SchemaSuite) TestUnknownSchema() {
+	_, err := Get("values")
+
+	suite.EqualError(err, "Unknown schema values. Must be one of [appfile appspec]")
+}
+
+func (suite *SchemaSuite) TestAppSpecSchemaCoversGodoFields() {
+	var appSpec map[string]interface{}
+	suite.Require().NoError(json.Unmarshal(appSpecSchema, &appSpec))
+
+	missing := []string{}
+	walkSchemaFields(appSpec, appSpec, reflect.TypeOf(godo.AppSpec{}), "(root)", &missing)
+
+	suite.Empty(missing, "Fields of godo.AppSpec missing in the app spec schema")
+}
+
+// walkSchemaFields records in missing the paths of the json fields of typ,
+// recursively, that are not declared as properties of the schema node
+func walkSchemaFields(root map[string]interface{}, node map[string]interface{}, typ reflect.Type, path string, missing *[]string) {
+	node = resolveRef(root, node)
+	for typ.Kind() == reflect.Ptr || typ.Kind() == reflect.Slice {
+		typ = typ.Elem()
+		if items, ok := node["items"].(map[string]interface{}); ok {
+			node = resolveRef(root, items)
+		}
+	}
+	if typ.Kind() != reflect.Struct {
+		return
+	}
+
+	properties, _ := node["properties"].(map[string]interface{})
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		if name == "" || name == "-" {
+			continue
+		}
+
+		property, ok := properties[name].(map[string]interface{})
+		if !ok {
+			*missing = append(*missing, path+"."+name)
+			continue
+		}
+		walkSchemaFields(root, property, field.Type, path+"."+name, missing)
+	}
+}
+
+func resolveRef(root map[string]interface{}, node map[string]interface{}) map[string]interface{} {
+	for {
+		ref, ok := node["$ref"].(string)
+		if !ok {
+			return node
+		}
+
+		definitions := root["definitions"].(map[string]interface{})
+		node = definitions[strings.TrimPrefix(ref, "#/definitions/")].(map[string]interface{})
+	}
+}
+
+func TestSchemaSuite(t *testing.T) {
+	suite.Run(t, &SchemaSuite{})
+}